@@ -6,6 +6,7 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	"github.com/nymphium/depextify/depextify"
@@ -20,6 +21,14 @@ type CLIConfig struct {
 	IgnoreCoreutils bool     `yaml:"no_coreutils"`
 	IgnoreCommon    bool     `yaml:"no_common"`
 	UseColor        bool     `yaml:"use_color"`
+	NixInputs       bool     `yaml:"nix_inputs"`
+	Jobs            int      `yaml:"jobs"`
+	CacheDir        string   `yaml:"cache_dir"`
+	CacheStats      bool     `yaml:"-"`
+	GitTracked      bool     `yaml:"git_tracked"`
+	Since           string   `yaml:"-"`
+	Rev             string   `yaml:"-"`
+	NoGitignore     bool     `yaml:"no_gitignore"`
 	List            string   `yaml:"-"`
 	Lexer           string   `yaml:"lexer"`
 	Style           string   `yaml:"style"`
@@ -27,9 +36,33 @@ type CLIConfig struct {
 	IgnoresStr string   `yaml:"-"`
 	Ignores    []string `yaml:"ignores"`
 	Excludes   []string `yaml:"excludes"`
+	DeniesStr  string   `yaml:"-"`
+	Denies     []string `yaml:"denies"`
 
-	Target string `yaml:"-"`
-	Format string `yaml:"format"`
+	Packages  map[string]string            `yaml:"packages"`
+	PackageDB map[string]map[string]string `yaml:"package_db"`
+	Generate  string                       `yaml:"-"`
+	Check     bool                         `yaml:"-"`
+	Path      string                       `yaml:"-"`
+	Rootfs    string                       `yaml:"-"`
+
+	Baseline       string `yaml:"-"`
+	UpdateBaseline bool   `yaml:"-"`
+
+	Policy      []depextify.PolicyRule `yaml:"policy"`
+	CheckPolicy bool                   `yaml:"-"`
+	// PolicyDir is the directory of the config file, which the paths of
+	// the policy are relative to.
+	PolicyDir string `yaml:"-"`
+
+	Verify bool   `yaml:"-"`
+	Lock   string `yaml:"lock"`
+
+	Targets       []string `yaml:"-"`
+	StdinFilename string   `yaml:"-"`
+	Format        string   `yaml:"format"`
+	GroupBy       string   `yaml:"group_by"`
+	SortBy        string   `yaml:"sort_by"`
 }
 
 func isTTY() bool {
@@ -45,6 +78,7 @@ func loadConfigFile(cfg *CLIConfig) {
 	if f, err := os.Open(".depextify.yaml"); err == nil {
 		defer func() { _ = f.Close() }()
 		_ = yaml.NewDecoder(f).Decode(cfg)
+		cfg.PolicyDir = "."
 		return
 	}
 
@@ -54,6 +88,7 @@ func loadConfigFile(cfg *CLIConfig) {
 		if f, err := os.Open(path); err == nil {
 			defer func() { _ = f.Close() }()
 			_ = yaml.NewDecoder(f).Decode(cfg)
+			cfg.PolicyDir = home
 		}
 	}
 }
@@ -67,6 +102,7 @@ func parseFlags(args []string) (*CLIConfig, error) {
 		Lexer:           depextify.DefaultLexer,
 		Style:           depextify.DefaultStyle,
 		Format:          "text",
+		Lock:            depextify.DefaultLockFile,
 	}
 
 	loadConfigFile(cfg)
@@ -76,6 +112,7 @@ func parseFlags(args []string) (*CLIConfig, error) {
 	fs.BoolVar(&cfg.ShowCount, "count", cfg.ShowCount, "show appearance count for each command")
 	fs.BoolVar(&cfg.ShowPos, "pos", cfg.ShowPos, "show file position and full line for each command")
 	fs.BoolVar(&cfg.ShowHidden, "hidden", cfg.ShowHidden, "scan hidden files and directories")
+	fs.BoolVar(&cfg.NixInputs, "nix-inputs", cfg.NixInputs, "also report packages listed in buildInputs/nativeBuildInputs/packages of Nix files")
 
 	// Pointers for [no-] flags. Descriptions are placed in one of the pair.
 	pBuilt := fs.Bool("builtin", false, "")
@@ -91,32 +128,108 @@ func parseFlags(args []string) (*CLIConfig, error) {
 	fs.StringVar(&cfg.Lexer, "lexer", cfg.Lexer, "chroma lexer name")
 	fs.StringVar(&cfg.Style, "style", cfg.Style, "chroma style name (env: DEPEXTIFY_STYLE)")
 	fs.StringVar(&cfg.IgnoresStr, "ignores", "", "comma-separated list of commands to ignore")
-	fs.StringVar(&cfg.Format, "format", cfg.Format, "output format (text, json, yaml)")
+	fs.StringVar(&cfg.DeniesStr, "denies", "", "comma-separated list of commands reported as denied in SARIF output, and forbidden by -policy")
+	fs.StringVar(&cfg.Format, "format", cfg.Format, "output format (text, json, yaml, sarif, cyclonedx, spdx, markdown, html)")
+	fs.StringVar(&cfg.GroupBy, "group-by", cfg.GroupBy, "group the output by \"file\" or \"command\"")
+	fs.StringVar(&cfg.SortBy, "sort", cfg.SortBy, "sort commands grouped by command by \"count\" or \"name\"")
+	fs.StringVar(&cfg.Generate, "generate", "", "print the packages providing the commands found, for an ecosystem ("+strings.Join(depextify.Ecosystems(), ", ")+")")
+	fs.BoolVar(&cfg.Check, "check", false, "check that the commands found are in PATH, and exit with 2 if some are missing")
+	fs.StringVar(&cfg.Path, "path", "", "PATH to check commands against with -check (default: $PATH, or the usual one with -rootfs)")
+	fs.StringVar(&cfg.Rootfs, "rootfs", "", "check the commands found against a root filesystem directory or image tarball instead of this system (implies -check)")
+	fs.BoolVar(&cfg.CheckPolicy, "policy", false, "check the commands found against the policy of .depextify.yaml and -denies, and exit with 5 if it is violated")
+	fs.BoolVar(&cfg.Verify, "verify", false, "compare the commands found with those the -lock file declares, and exit with 6 if they differ")
+	fs.StringVar(&cfg.Lock, "lock", cfg.Lock, "manifest of the declared commands for -verify")
+	fs.StringVar(&cfg.Baseline, "baseline", "", "compare the commands found with a baseline file, and exit with 3 if new commands are used, or 4 if commands were only removed")
+	fs.BoolVar(&cfg.UpdateBaseline, "update-baseline", false, "write the commands found to the -baseline file instead of comparing them")
+	fs.IntVar(&cfg.Jobs, "jobs", cfg.Jobs, "number of files to process in parallel (default: number of CPUs)")
+	fs.StringVar(&cfg.CacheDir, "cache-dir", cfg.CacheDir, "directory to cache the results of unchanged files in between runs")
+	fs.BoolVar(&cfg.CacheStats, "cache-stats", false, "print cache hits and misses to stderr")
+	fs.BoolVar(&cfg.GitTracked, "git-tracked", cfg.GitTracked, "scan only files tracked by git")
+	fs.StringVar(&cfg.Since, "since", "", "scan only files changed since a git revision, including uncommitted ones")
+	fs.StringVar(&cfg.Rev, "rev", "", "scan the tree of a git revision instead of the working tree")
+	fs.BoolVar(&cfg.NoGitignore, "no-gitignore", cfg.NoGitignore, "do not skip the files .gitignore and .git/info/exclude ignore")
+	fs.StringVar(&cfg.StdinFilename, "stdin-filename", "", "file name to report stdin (target \"-\") as, which selects its extractor")
 
 	fs.Usage = func() {
 		u := func(name string) string { return fs.Lookup(name).Usage }
-		fmt.Fprintf(os.Stderr, "Usage: depextify [options] <file|directory>\n\nOptions:\n")
+		fmt.Fprintf(os.Stderr, "Usage: depextify [options] <file|directory|archive|->...\n")
+		fmt.Fprintf(os.Stderr, "       depextify generate <ecosystem> [options] <target>...\n")
+		fmt.Fprintf(os.Stderr, "       depextify doctor [options] <target>...\n")
+		fmt.Fprintf(os.Stderr, "       depextify policy [options] <target>...\n")
+		fmt.Fprintf(os.Stderr, "       depextify verify [options] <target>...\n\nOptions:\n")
 		fmt.Fprintf(os.Stderr, "  -count\n    \t%s\n", u("count"))
 		fmt.Fprintf(os.Stderr, "  -pos\n    \t%s\n", u("pos"))
 		fmt.Fprintf(os.Stderr, "  -hidden\n    \t%s\n", u("hidden"))
+		fmt.Fprintf(os.Stderr, "  -nix-inputs\n    \t%s\n", u("nix-inputs"))
 		fmt.Fprintf(os.Stderr, "  -[no-]builtin\n    \t%s\n", u("no-builtin"))
 		fmt.Fprintf(os.Stderr, "  -[no-]coreutils\n    \t%s\n", u("no-coreutils"))
 		fmt.Fprintf(os.Stderr, "  -[no-]common\n    \t%s\n", u("no-common"))
 		fmt.Fprintf(os.Stderr, "  -[no-]color\n    \t%s\n", u("no-color"))
 		fmt.Fprintf(os.Stderr, "  -ignores string\n    \t%s\n", u("ignores"))
+		fmt.Fprintf(os.Stderr, "  -denies string\n    \t%s\n", u("denies"))
 		fmt.Fprintf(os.Stderr, "  -list string\n    \t%s\n", u("list"))
 		fmt.Fprintf(os.Stderr, "  -lexer string\n    \t%s (default: %q)\n", u("lexer"), depextify.DefaultLexer)
 		fmt.Fprintf(os.Stderr, "  -style string\n    \t%s (default: %q)\n", u("style"), depextify.DefaultStyle)
 		fmt.Fprintf(os.Stderr, "  -format string\n    \t%s (default: \"text\")\n", u("format"))
+		fmt.Fprintf(os.Stderr, "  -check\n    \t%s\n", u("check"))
+		fmt.Fprintf(os.Stderr, "  -path string\n    \t%s\n", u("path"))
+		fmt.Fprintf(os.Stderr, "  -rootfs string\n    \t%s\n", u("rootfs"))
+		fmt.Fprintf(os.Stderr, "  -policy\n    \t%s\n", u("policy"))
+		fmt.Fprintf(os.Stderr, "  -verify\n    \t%s\n", u("verify"))
+		fmt.Fprintf(os.Stderr, "  -lock string\n    \t%s (default: %q)\n", u("lock"), depextify.DefaultLockFile)
+		fmt.Fprintf(os.Stderr, "  -baseline string\n    \t%s\n", u("baseline"))
+		fmt.Fprintf(os.Stderr, "  -update-baseline\n    \t%s\n", u("update-baseline"))
+		fmt.Fprintf(os.Stderr, "  -generate string\n    \t%s\n", u("generate"))
+		fmt.Fprintf(os.Stderr, "  -group-by string\n    \t%s (default: \"file\")\n", u("group-by"))
+		fmt.Fprintf(os.Stderr, "  -sort string\n    \t%s (default: \"count\")\n", u("sort"))
+		fmt.Fprintf(os.Stderr, "  -stdin-filename string\n    \t%s\n", u("stdin-filename"))
+		fmt.Fprintf(os.Stderr, "  -jobs int\n    \t%s\n", u("jobs"))
+		fmt.Fprintf(os.Stderr, "  -cache-dir string\n    \t%s\n", u("cache-dir"))
+		fmt.Fprintf(os.Stderr, "  -cache-stats\n    \t%s\n", u("cache-stats"))
+		fmt.Fprintf(os.Stderr, "  -git-tracked\n    \t%s\n", u("git-tracked"))
+		fmt.Fprintf(os.Stderr, "  -since string\n    \t%s\n", u("since"))
+		fmt.Fprintf(os.Stderr, "  -rev string\n    \t%s\n", u("rev"))
+		fmt.Fprintf(os.Stderr, "  -no-gitignore\n    \t%s\n", u("no-gitignore"))
+	}
+
+	// Subcommands are shorthands for their flag: "generate apt" is "-generate
+	// apt". Targets of the same name are given as ./verify or after "--".
+	if len(args) > 0 {
+		switch args[0] {
+		case "generate":
+			args = append([]string{"-generate"}, args[1:]...)
+		case "doctor":
+			args = append([]string{"-check"}, args[1:]...)
+		case "policy":
+			args = append([]string{"-policy"}, args[1:]...)
+		case "verify":
+			args = append([]string{"-verify"}, args[1:]...)
+		}
 	}
 
 	var positional []string
 	var flagArgs []string
-	for _, arg := range args {
-		if strings.HasPrefix(arg, "-") {
-			flagArgs = append(flagArgs, arg)
-		} else {
+	// Names of the flags given, in order
+	var flagNames []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			positional = append(positional, args[i+1:]...)
+			break
+		}
+		// "-" is stdin
+		if arg == "-" || !strings.HasPrefix(arg, "-") {
 			positional = append(positional, arg)
+			continue
+		}
+
+		flagArgs = append(flagArgs, arg)
+		name, _, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		flagNames = append(flagNames, name)
+		// Non-boolean flags may take their value as the next argument: -format json
+		if f := fs.Lookup(name); f != nil && !hasValue && !isBoolFlag(f) && i+1 < len(args) {
+			i++
+			flagArgs = append(flagArgs, args[i])
 		}
 	}
 
@@ -125,11 +238,7 @@ func parseFlags(args []string) (*CLIConfig, error) {
 	}
 
 	// Honor the order of flags provided on CLI (last one wins)
-	for _, f := range flagArgs {
-		name := strings.TrimLeft(f, "-")
-		if idx := strings.Index(name, "="); idx != -1 {
-			name = name[:idx]
-		}
+	for _, name := range flagNames {
 		switch name {
 		case "builtin":
 			cfg.IgnoreBuiltins = !*pBuilt
@@ -166,14 +275,59 @@ func parseFlags(args []string) (*CLIConfig, error) {
 		return nil, fmt.Errorf("no target specified")
 	}
 
-	cfg.Target = positional[0]
+	cfg.Targets = positional
+	if cfg.Jobs < 0 {
+		return nil, fmt.Errorf("-jobs must not be negative")
+	}
+	if cfg.Rootfs != "" {
+		cfg.Check = true
+	}
+	if cfg.Check && cfg.Generate != "" {
+		return nil, fmt.Errorf("-check cannot be used with -generate")
+	}
+	if cfg.Baseline != "" && (cfg.Check || cfg.Generate != "") {
+		return nil, fmt.Errorf("-baseline cannot be used with -check or -generate")
+	}
+	if cfg.CheckPolicy && (cfg.Check || cfg.Generate != "" || cfg.Baseline != "") {
+		return nil, fmt.Errorf("-policy cannot be used with -check, -generate or -baseline")
+	}
+	if cfg.Verify && (cfg.Check || cfg.Generate != "" || cfg.Baseline != "" || cfg.CheckPolicy) {
+		return nil, fmt.Errorf("-verify cannot be used with -check, -generate, -baseline or -policy")
+	}
+	if cfg.UpdateBaseline && cfg.Baseline == "" {
+		return nil, fmt.Errorf("-update-baseline requires -baseline")
+	}
+	if cfg.Generate != "" && !slices.Contains(depextify.Ecosystems(), cfg.Generate) {
+		return nil, fmt.Errorf("-generate must be one of %s", strings.Join(depextify.Ecosystems(), ", "))
+	}
+	switch cfg.GroupBy {
+	case "", depextify.GroupByFile, depextify.GroupByCommand:
+	default:
+		return nil, fmt.Errorf("-group-by must be %q or %q", depextify.GroupByFile, depextify.GroupByCommand)
+	}
+	switch cfg.SortBy {
+	case "", depextify.SortByCount, depextify.SortByName:
+	default:
+		return nil, fmt.Errorf("-sort must be %q or %q", depextify.SortByCount, depextify.SortByName)
+	}
 	if cfg.IgnoresStr != "" {
 		cfg.Ignores = append(cfg.Ignores, strings.Split(cfg.IgnoresStr, ",")...)
 	}
+	if cfg.DeniesStr != "" {
+		cfg.Denies = append(cfg.Denies, strings.Split(cfg.DeniesStr, ",")...)
+	}
+	if cfg.CheckPolicy && len(cfg.Policy) == 0 && len(cfg.Denies) == 0 {
+		return nil, fmt.Errorf("-policy needs rules: set policy: in .depextify.yaml, or -denies")
+	}
 
 	return cfg, nil
 }
 
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
 func printCategory(w io.Writer, name string, commands []string, useColor bool) {
 	header := name + ":"
 	if useColor {
@@ -2,6 +2,7 @@ package main
 
 import (
 	"bytes"
+	"os"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -11,7 +12,7 @@ func TestParseFlags(t *testing.T) {
 	t.Run("default flags", func(t *testing.T) {
 		cfg, err := parseFlags([]string{"target.sh"})
 		require.NoError(t, err)
-		require.Equal(t, "target.sh", cfg.Target)
+		require.Equal(t, []string{"target.sh"}, cfg.Targets)
 		require.True(t, cfg.IgnoreBuiltins)
 		require.True(t, cfg.IgnoreCoreutils)
 		require.True(t, cfg.IgnoreCommon)
@@ -22,7 +23,7 @@ func TestParseFlags(t *testing.T) {
 	t.Run("override flags", func(t *testing.T) {
 		cfg, err := parseFlags([]string{"-count", "-pos", "-builtin", "-coreutils=false", "target.sh"})
 		require.NoError(t, err)
-		require.Equal(t, "target.sh", cfg.Target)
+		require.Equal(t, []string{"target.sh"}, cfg.Targets)
 		require.False(t, cfg.IgnoreBuiltins)
 		require.True(t, cfg.IgnoreCoreutils) // -coreutils=false means ignore=true
 		require.True(t, cfg.IgnoreCommon)
@@ -30,6 +31,12 @@ func TestParseFlags(t *testing.T) {
 		require.True(t, cfg.ShowPos)
 	})
 
+	t.Run("nix inputs", func(t *testing.T) {
+		cfg, err := parseFlags([]string{"-nix-inputs", "flake.nix"})
+		require.NoError(t, err)
+		require.True(t, cfg.NixInputs)
+	})
+
 	t.Run("last-one-wins", func(t *testing.T) {
 		cfg, err := parseFlags([]string{"-common", "-no-common", "target.sh"})
 		require.NoError(t, err)
@@ -40,6 +47,140 @@ func TestParseFlags(t *testing.T) {
 		require.False(t, cfg.IgnoreCommon)
 	})
 
+	t.Run("multiple targets and stdin", func(t *testing.T) {
+		cfg, err := parseFlags([]string{"scripts", "-stdin-filename", "Makefile", "-", "-format", "json", "Dockerfile", "--", "-odd.sh"})
+		require.NoError(t, err)
+		require.Equal(t, []string{"scripts", "-", "Dockerfile", "-odd.sh"}, cfg.Targets)
+		require.Equal(t, "Makefile", cfg.StdinFilename)
+		require.Equal(t, "json", cfg.Format)
+	})
+
+	t.Run("jobs", func(t *testing.T) {
+		cfg, err := parseFlags([]string{"-jobs", "4", "."})
+		require.NoError(t, err)
+		require.Equal(t, 4, cfg.Jobs)
+		require.Equal(t, []string{"."}, cfg.Targets)
+
+		_, err = parseFlags([]string{"-jobs=-1", "."})
+		require.Error(t, err)
+	})
+
+	t.Run("git", func(t *testing.T) {
+		cfg, err := parseFlags([]string{"-git-tracked", "-since", "origin/main", "-rev=HEAD~1", "-no-gitignore", "."})
+		require.NoError(t, err)
+		require.True(t, cfg.GitTracked)
+		require.True(t, cfg.NoGitignore)
+		require.Equal(t, "origin/main", cfg.Since)
+		require.Equal(t, "HEAD~1", cfg.Rev)
+		require.Equal(t, []string{"."}, cfg.Targets)
+	})
+
+	t.Run("group by", func(t *testing.T) {
+		cfg, err := parseFlags([]string{"-group-by", "command", "-sort=name", "."})
+		require.NoError(t, err)
+		require.Equal(t, "command", cfg.GroupBy)
+		require.Equal(t, "name", cfg.SortBy)
+
+		_, err = parseFlags([]string{"-group-by=dir", "."})
+		require.Error(t, err)
+		_, err = parseFlags([]string{"-sort=size", "."})
+		require.Error(t, err)
+	})
+
+	t.Run("generate", func(t *testing.T) {
+		cfg, err := parseFlags([]string{"generate", "nix", "-hidden", "."})
+		require.NoError(t, err)
+		require.Equal(t, "nix", cfg.Generate)
+		require.True(t, cfg.ShowHidden)
+		require.Equal(t, []string{"."}, cfg.Targets)
+
+		cfg, err = parseFlags([]string{"-generate=apt", "generate"})
+		require.NoError(t, err)
+		require.Equal(t, "apt", cfg.Generate)
+		require.Equal(t, []string{"generate"}, cfg.Targets)
+
+		_, err = parseFlags([]string{"generate", "pacman", "."})
+		require.Error(t, err)
+	})
+
+	t.Run("target named like a subcommand", func(t *testing.T) {
+		// Whether a file of that name exists does not matter
+		t.Chdir(t.TempDir())
+		require.NoError(t, os.Mkdir("verify", 0o755))
+
+		cfg, err := parseFlags([]string{"verify", "."})
+		require.NoError(t, err)
+		require.True(t, cfg.Verify)
+		require.Equal(t, []string{"."}, cfg.Targets)
+
+		cfg, err = parseFlags([]string{"./verify"})
+		require.NoError(t, err)
+		require.False(t, cfg.Verify)
+		require.Equal(t, []string{"./verify"}, cfg.Targets)
+
+		cfg, err = parseFlags([]string{"--", "verify"})
+		require.NoError(t, err)
+		require.False(t, cfg.Verify)
+		require.Equal(t, []string{"verify"}, cfg.Targets)
+
+		cfg, err = parseFlags([]string{"doctor", "verify"})
+		require.NoError(t, err)
+		require.True(t, cfg.Check)
+		require.Equal(t, []string{"verify"}, cfg.Targets)
+	})
+
+	t.Run("doctor", func(t *testing.T) {
+		cfg, err := parseFlags([]string{"doctor", "-path", "/opt/bin", "."})
+		require.NoError(t, err)
+		require.True(t, cfg.Check)
+		require.Equal(t, "/opt/bin", cfg.Path)
+		require.Equal(t, []string{"."}, cfg.Targets)
+
+		_, err = parseFlags([]string{"doctor", "-generate=apt", "."})
+		require.Error(t, err)
+
+		cfg, err = parseFlags([]string{"-rootfs", "image.tar", "."})
+		require.NoError(t, err)
+		require.True(t, cfg.Check)
+		require.Equal(t, "image.tar", cfg.Rootfs)
+	})
+
+	t.Run("baseline", func(t *testing.T) {
+		cfg, err := parseFlags([]string{"-baseline", "deps.json", "-update-baseline", "."})
+		require.NoError(t, err)
+		require.Equal(t, "deps.json", cfg.Baseline)
+		require.True(t, cfg.UpdateBaseline)
+
+		_, err = parseFlags([]string{"-update-baseline", "."})
+		require.Error(t, err)
+		_, err = parseFlags([]string{"doctor", "-baseline", "deps.json", "."})
+		require.Error(t, err)
+	})
+
+	t.Run("policy", func(t *testing.T) {
+		cfg, err := parseFlags([]string{"policy", "-denies", "sudo", "."})
+		require.NoError(t, err)
+		require.True(t, cfg.CheckPolicy)
+		require.Equal(t, []string{"sudo"}, cfg.Denies)
+
+		_, err = parseFlags([]string{"policy", "-generate=apt", "-denies", "sudo", "."})
+		require.Error(t, err)
+	})
+
+	t.Run("verify", func(t *testing.T) {
+		cfg, err := parseFlags([]string{"verify", "."})
+		require.NoError(t, err)
+		require.True(t, cfg.Verify)
+		require.Equal(t, "depextify.lock.yaml", cfg.Lock)
+
+		cfg, err = parseFlags([]string{"verify", "-lock", "tools.yaml", "."})
+		require.NoError(t, err)
+		require.Equal(t, "tools.yaml", cfg.Lock)
+
+		_, err = parseFlags([]string{"verify", "-check", "."})
+		require.Error(t, err)
+	})
+
 	t.Run("list flag conflicts", func(t *testing.T) {
 		_, err := parseFlags([]string{"-list=all", "target.sh"})
 		require.Error(t, err)
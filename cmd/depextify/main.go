@@ -8,13 +8,29 @@ import (
 	"github.com/nymphium/depextify/depextify"
 )
 
+// Exit codes
+const (
+	exitError = 1
+	// exitMissing is returned by -check when commands are missing.
+	exitMissing = 2
+	// exitAdded is returned by -baseline when new commands are used.
+	exitAdded = 3
+	// exitRemoved is returned by -baseline when commands were only removed.
+	exitRemoved = 4
+	// exitPolicy is returned by -policy when the policy is violated.
+	exitPolicy = 5
+	// exitDrift is returned by -verify when the commands found are not
+	// those declared.
+	exitDrift = 6
+)
+
 func main() {
 	cfg, err := parseFlags(os.Args[1:])
 	if err != nil {
 		if err.Error() != "no target specified" {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		}
-		os.Exit(1)
+		os.Exit(exitError)
 	}
 
 	if cfg.List != "" {
@@ -47,34 +63,173 @@ func main() {
 		return
 	}
 
-	// Extra ignores are already merged into cfg.Ignores in parseFlags
+	scanConfig := newScanConfig(cfg)
+	results, err := scanConfig.ScanTargets(cfg.Targets, os.Stdin)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(exitError)
+	}
 
-	scanConfig := &depextify.Config{
-		NoBuiltins:   cfg.IgnoreBuiltins,
-		NoCoreutils:  cfg.IgnoreCoreutils,
-		NoCommon:     cfg.IgnoreCommon,
-		ShowHidden:   cfg.ShowHidden,
-		ExtraIgnores: cfg.Ignores,
-		Excludes:     cfg.Excludes,
-		ShowCount:    cfg.ShowCount,
-		ShowPos:      cfg.ShowPos,
-		UseColor:     cfg.UseColor,
-		LexerName:    cfg.Lexer,
-		StyleName:    cfg.Style,
-		Format:       cfg.Format,
+	if cfg.CacheStats {
+		stats := scanConfig.CacheStats()
+		fmt.Fprintf(os.Stderr, "cache: %d hits, %d misses\n", stats.Hits, stats.Misses)
 	}
 
-	results, err := scanConfig.Scan(cfg.Target)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+	if cfg.Check {
+		var checks depextify.Checks
+		if cfg.Rootfs != "" {
+			checks, err = results.CheckRoot(cfg.Rootfs, cfg.Path)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(exitError)
+			}
+		} else {
+			pathList := cfg.Path
+			if pathList == "" {
+				pathList = os.Getenv("PATH")
+			}
+			checks = results.Check(pathList)
+		}
+
+		var out string
+		switch cfg.Format {
+		case "json":
+			out, err = checks.JSON()
+		case "yaml":
+			out, err = checks.YAML()
+		default:
+			out = checks.Format(scanConfig)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(exitError)
+		}
+		if out != "" {
+			fmt.Println(strings.TrimSuffix(out, "\n"))
+		}
+		if len(checks.Missing()) > 0 {
+			os.Exit(exitMissing)
+		}
+		return
+	}
+
+	if cfg.Generate != "" {
+		manifest, err := results.Manifest(scanConfig, cfg.Generate)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(exitError)
+		}
+		fmt.Print(manifest)
+		if len(manifest.Unmapped) > 0 {
+			fmt.Fprintf(os.Stderr, "Warning: no %s package known for: %s\n", cfg.Generate, strings.Join(manifest.Unmapped, ", "))
+		}
+		return
+	}
+
+	if cfg.CheckPolicy {
+		violations, err := results.CheckPolicy(scanConfig)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(exitError)
+		}
+
+		var out string
+		switch cfg.Format {
+		case "json":
+			out, err = violations.JSON()
+		case "yaml":
+			out, err = violations.YAML()
+		default:
+			out = violations.Format(scanConfig)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(exitError)
+		}
+		if out != "" {
+			fmt.Println(strings.TrimSuffix(out, "\n"))
+		}
+		if len(violations) > 0 {
+			os.Exit(exitPolicy)
+		}
+		return
+	}
+
+	if cfg.Verify {
+		drift, err := verify(cfg, results)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(exitError)
+		}
+
+		var out string
+		switch cfg.Format {
+		case "json":
+			out, err = drift.JSON()
+		case "yaml":
+			out, err = drift.YAML()
+		default:
+			out = drift.Format(scanConfig)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(exitError)
+		}
+		if out != "" {
+			fmt.Println(strings.TrimSuffix(out, "\n"))
+		}
+		if !drift.Empty() {
+			os.Exit(exitDrift)
+		}
+		return
+	}
+
+	if cfg.Baseline != "" {
+		if cfg.UpdateBaseline {
+			if err := results.Baseline().WriteFile(cfg.Baseline); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(exitError)
+			}
+			return
+		}
+
+		baseline, err := depextify.ReadBaseline(cfg.Baseline)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(exitError)
+		}
+		diff := baseline.Diff(results.Baseline())
+
+		var out string
+		switch cfg.Format {
+		case "json":
+			out, err = diff.JSON()
+		case "yaml":
+			out, err = diff.YAML()
+		default:
+			out = diff.Format(scanConfig)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(exitError)
+		}
+		if out != "" {
+			fmt.Println(strings.TrimSuffix(out, "\n"))
+		}
+		switch {
+		case len(diff.Added) > 0:
+			os.Exit(exitAdded)
+		case len(diff.Removed) > 0:
+			os.Exit(exitRemoved)
+		}
+		return
 	}
 
 	if cfg.Format == "json" {
 		out, err := results.JSON(scanConfig)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error formatting JSON: %v\n", err)
-			os.Exit(1)
+			os.Exit(exitError)
 		}
 		fmt.Println(out)
 		return
@@ -84,11 +239,120 @@ func main() {
 		out, err := results.YAML(scanConfig)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error formatting YAML: %v\n", err)
-			os.Exit(1)
+			os.Exit(exitError)
+		}
+		fmt.Println(out)
+		return
+	}
+
+	if cfg.Format == "sarif" {
+		out, err := results.SARIF(scanConfig)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error formatting SARIF: %v\n", err)
+			os.Exit(exitError)
 		}
 		fmt.Println(out)
 		return
 	}
 
+	if cfg.Format == "cyclonedx" {
+		out, err := results.CycloneDX(scanConfig)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error formatting CycloneDX: %v\n", err)
+			os.Exit(exitError)
+		}
+		fmt.Println(out)
+		return
+	}
+
+	if cfg.Format == "spdx" {
+		out, err := results.SPDX(scanConfig)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error formatting SPDX: %v\n", err)
+			os.Exit(exitError)
+		}
+		fmt.Println(out)
+		return
+	}
+
+	if cfg.Format == "markdown" {
+		fmt.Print(results.Markdown(scanConfig))
+		return
+	}
+
+	if cfg.Format == "html" {
+		out, err := results.HTML(scanConfig)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error formatting HTML: %v\n", err)
+			os.Exit(exitError)
+		}
+		fmt.Print(out)
+		return
+	}
+
 	fmt.Print(results.Format(scanConfig))
-}
\ No newline at end of file
+}
+
+// newScanConfig returns the configuration of the scan cfg asks for.
+func newScanConfig(cfg *CLIConfig) *depextify.Config {
+	// Extra ignores are already merged into cfg.Ignores in parseFlags
+	scanConfig := &depextify.Config{
+		NoBuiltins:   cfg.IgnoreBuiltins,
+		NoCoreutils:  cfg.IgnoreCoreutils,
+		NoCommon:     cfg.IgnoreCommon,
+		ShowHidden:   cfg.ShowHidden,
+		NixInputs:    cfg.NixInputs,
+		ExtraIgnores: cfg.Ignores,
+		Excludes:     cfg.Excludes,
+		Denies:       cfg.Denies,
+		Policy:       cfg.Policy,
+		PolicyDir:    cfg.PolicyDir,
+		Packages:     cfg.Packages,
+		PackageDB:    cfg.PackageDB,
+		ShowCount:    cfg.ShowCount,
+		ShowPos:      cfg.ShowPos,
+		UseColor:     cfg.UseColor,
+		LexerName:    cfg.Lexer,
+		StyleName:    cfg.Style,
+		Format:       cfg.Format,
+		GroupBy:      cfg.GroupBy,
+		SortBy:       cfg.SortBy,
+		Jobs:         cfg.Jobs,
+		CacheDir:     cfg.CacheDir,
+		GitTracked:   cfg.GitTracked,
+		Since:        cfg.Since,
+		Rev:          cfg.Rev,
+		NoGitignore:  cfg.NoGitignore,
+
+		StdinFilename: cfg.StdinFilename,
+	}
+
+	if cfg.CheckPolicy || cfg.Check || cfg.Verify {
+		// The policy applies to every command, not only those reported, and
+		// so do the checks of installed and declared commands
+		scanConfig.NoBuiltins = false
+		scanConfig.NoCoreutils = false
+		scanConfig.NoCommon = false
+		scanConfig.ExtraIgnores = nil
+	}
+
+	return scanConfig
+}
+
+// verify compares results, a scan of every command, with the lock file of
+// cfg.
+func verify(cfg *CLIConfig, results depextify.ScanResult) (depextify.Drift, error) {
+	lock, err := depextify.ReadLock(cfg.Lock)
+	if err != nil {
+		return depextify.Drift{}, err
+	}
+	// Declared commands are used even if the report hides them, but
+	// undeclared ones are only those it shows.
+	filters := &depextify.Config{
+		NoBuiltins:   cfg.IgnoreBuiltins,
+		NoCoreutils:  cfg.IgnoreCoreutils,
+		NoCommon:     cfg.IgnoreCommon,
+		ExtraIgnores: cfg.Ignores,
+	}
+	return results.Verify(filters, lock), nil
+}
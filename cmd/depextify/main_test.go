@@ -59,3 +59,66 @@ func TestExamples(t *testing.T) {
 		}
 	})
 }
+
+// scan runs the scan the command line args asks for in dir.
+func scan(t *testing.T, dir string, args ...string) (*CLIConfig, *depextify.Config, depextify.ScanResult) {
+	t.Helper()
+	t.Chdir(dir)
+	t.Setenv("HOME", t.TempDir())
+
+	cfg, err := parseFlags(args)
+	require.NoError(t, err)
+	scanConfig := newScanConfig(cfg)
+	res, err := scanConfig.ScanTargets(cfg.Targets, nil)
+	require.NoError(t, err)
+	return cfg, scanConfig, res
+}
+
+func TestCheck_AllCategories(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "setup.sh"), []byte("#!/bin/sh\ncd /tmp\ncat notes | curl -d @- example.com\nterraform apply\n"), 0o644))
+
+	// Hidden commands must be installed too
+	_, _, res := scan(t, dir, "doctor", "-ignores", "terraform", ".")
+	checks := res.Check(t.TempDir())
+	var cmds []string
+	for _, c := range checks {
+		cmds = append(cmds, c.Command)
+	}
+	require.Equal(t, []string{"cat", "cd", "curl", "terraform"}, cmds)
+	require.Equal(t, []string{"cat", "curl", "terraform"}, checks.Missing())
+}
+
+func TestCheckRoot_AllCategories(t *testing.T) {
+	dir, root := t.TempDir(), t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "entrypoint.sh"), []byte("#!/bin/sh\ncd /\ncat /etc/motd\ncurl example.com\n"), 0o644))
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "bin"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "bin", "cat"), nil, 0o755))
+
+	// An image lacking coreutils is caught
+	cfg, _, res := scan(t, dir, "-rootfs", root, ".")
+	checks, err := res.CheckRoot(cfg.Rootfs, cfg.Path)
+	require.NoError(t, err)
+	require.Len(t, checks, 3)
+	require.Equal(t, "/bin/cat", checks[0].Path)
+	require.True(t, checks[1].Builtin)
+	require.Equal(t, []string{"curl"}, checks.Missing())
+}
+
+func TestVerify_DeclaredCommonTool(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "deploy.sh"), []byte("#!/bin/sh\ncd /srv\ncurl -fsSL example.com | jq .\naws s3 ls\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, depextify.DefaultLockFile), []byte("commands:\n  - command: curl\n  - command: jq\n"), 0o644))
+
+	// curl is a common tool the report hides, but it is used
+	cfg, _, res := scan(t, dir, "verify", ".")
+	drift, err := verify(cfg, res)
+	require.NoError(t, err)
+	require.Empty(t, drift.Unused)
+	require.Equal(t, []depextify.UndeclaredCommand{{Command: "aws", Files: []string{"deploy.sh"}}}, drift.Undeclared)
+
+	cfg, _, res = scan(t, dir, "verify", "-ignores", "aws", ".")
+	drift, err = verify(cfg, res)
+	require.NoError(t, err)
+	require.True(t, drift.Empty())
+}
@@ -0,0 +1,430 @@
+package depextify
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// archiveSep separates the path of an archive from the path of a file inside
+// it in scan results, e.g. "release.tar.gz!/bin/install.sh".
+const archiveSep = "!/"
+
+const (
+	// Whiteout files mark paths deleted by an image layer, see
+	// https://github.com/opencontainers/image-spec/blob/main/layer.md#whiteouts
+	whiteoutPrefix = ".wh."
+	whiteoutOpaque = ".wh..wh..opq"
+
+	// Largest image metadata file (manifest, index) read in memory
+	maxImageMetadata = 4 << 20
+)
+
+var (
+	reArchive = regexp.MustCompile(`\.(tar|tar\.gz|tgz|zip)$`)
+
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+type (
+	// layerEntries holds the paths an image layer adds or deletes.
+	layerEntries struct {
+		// files maps non-directory paths to whether they are regular files
+		files     map[string]bool
+		whiteouts []string
+		opaques   []string
+	}
+
+	ociDescriptor struct {
+		Digest string `json:"digest"`
+	}
+
+	// ociManifest is either an image index or an image manifest.
+	ociManifest struct {
+		Manifests []ociDescriptor `json:"manifests"`
+		Layers    []ociDescriptor `json:"layers"`
+	}
+)
+
+// isArchive reports whether path is an archive Scan looks into.
+func isArchive(path string) bool {
+	return reArchive.MatchString(path)
+}
+
+// cleanEntryName returns the path of an archive entry relative to the
+// archive root, e.g. "bin/run.sh" for "./bin/run.sh", or "" for the root.
+func cleanEntryName(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+name), "/")
+}
+
+// decompress returns the content of r, gunzipping it if needed.
+func decompress(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	magic, _ := br.Peek(len(zstdMagic))
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		return gzip.NewReader(br)
+	case bytes.HasPrefix(magic, zstdMagic):
+		return nil, errors.New("zstd compression is not supported")
+	}
+	return br, nil
+}
+
+// eachTarEntry calls fn for each entry of tr.
+func eachTarEntry(tr *tar.Reader, fn func(hdr *tar.Header, r io.Reader) error) error {
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(hdr, tr); err != nil {
+			return err
+		}
+	}
+}
+
+// walkTar calls fn for each regular file of the tar archive at path in fsys,
+// which may be gzipped.
+func walkTar(fsys fs.FS, archive string, fn func(hdr *tar.Header, r io.Reader) error) error {
+	return walkTarEntries(fsys, archive, func(hdr *tar.Header, r io.Reader) error {
+		if hdr.Typeflag != tar.TypeReg {
+			return nil
+		}
+		return fn(hdr, r)
+	})
+}
+
+// walkTarEntries calls fn for each entry of the tar archive at path in fsys,
+// which may be gzipped.
+func walkTarEntries(fsys fs.FS, archive string, fn func(hdr *tar.Header, r io.Reader) error) error {
+	f, err := fsys.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+
+	// Keep the file itself as the reader of uncompressed archives when it is
+	// seekable, so the tar reader can seek over the entries it skips.
+	var r io.Reader
+	if rs, ok := f.(io.ReadSeeker); ok {
+		r = rs
+		magic := make([]byte, len(gzipMagic))
+		if _, err := io.ReadFull(rs, magic); err == nil && bytes.Equal(magic, gzipMagic) {
+			r = nil
+		}
+		if _, err := rs.Seek(0, io.SeekStart); err != nil {
+			return fmt.Errorf("%s: %w", archive, err)
+		}
+	}
+	if r == nil {
+		if r, err = decompress(f); err != nil {
+			return fmt.Errorf("%s: %w", archive, err)
+		}
+	}
+
+	if err := eachTarEntry(tar.NewReader(r), fn); err != nil {
+		return fmt.Errorf("%s: %w", archive, err)
+	}
+	return nil
+}
+
+// walkZip calls fn for each regular file of the zip archive at path in fsys.
+func walkZip(fsys fs.FS, archive string, fn func(name string, r io.Reader)) error {
+	f, err := fsys.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+
+	// The central directory is at the end of the file, so it needs random access
+	ra, ok := f.(io.ReaderAt)
+	var size int64
+	if ok {
+		info, err := f.Stat()
+		if err != nil {
+			return err
+		}
+		size = info.Size()
+	} else {
+		content, err := io.ReadAll(f)
+		if err != nil {
+			return err
+		}
+		ra, size = bytes.NewReader(content), int64(len(content))
+	}
+
+	zr, err := zip.NewReader(ra, size)
+	if err != nil {
+		return fmt.Errorf("%s: %w", archive, err)
+	}
+	for _, zf := range zr.File {
+		if !zf.Mode().IsRegular() {
+			continue
+		}
+		rc, err := zf.Open()
+		if err != nil {
+			continue
+		}
+		fn(zf.Name, rc)
+		_ = rc.Close()
+	}
+	return nil
+}
+
+// blobPath returns the path of the blob with the given digest in an OCI image layout.
+func blobPath(digest string) string {
+	alg, hex, _ := strings.Cut(digest, ":")
+	return "blobs/" + alg + "/" + hex
+}
+
+// readImageLayers returns the paths of the layers, lowest first, of the image
+// in a `docker save` or OCI image layout tarball. It returns nil if archive
+// is not an image. Only the first image of the tarball is considered.
+func readImageLayers(fsys fs.FS, archive string) ([]string, error) {
+	meta := make(map[string][]byte)
+	err := walkTar(fsys, archive, func(hdr *tar.Header, r io.Reader) error {
+		name := cleanEntryName(hdr.Name)
+		if hdr.Size > maxImageMetadata || (name != "manifest.json" && name != "index.json" && name != "oci-layout" && !strings.HasPrefix(name, "blobs/")) {
+			return nil
+		}
+		// Layers are blobs too, keep JSON documents only
+		br := bufio.NewReader(r)
+		if b, _ := br.Peek(1); len(b) == 0 || (b[0] != '{' && b[0] != '[') {
+			return nil
+		}
+		content, err := io.ReadAll(br)
+		if err != nil {
+			return err
+		}
+		meta[name] = content
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// docker save
+	if content, ok := meta["manifest.json"]; ok {
+		var manifests []struct{ Layers []string }
+		if err := json.Unmarshal(content, &manifests); err == nil && len(manifests) > 0 {
+			layers := make([]string, 0, len(manifests[0].Layers))
+			for _, l := range manifests[0].Layers {
+				layers = append(layers, cleanEntryName(l))
+			}
+			return layers, nil
+		}
+	}
+
+	// OCI image layout
+	content, ok := meta["index.json"]
+	if _, layout := meta["oci-layout"]; !ok || !layout {
+		return nil, nil
+	}
+	// Follow nested indexes, e.g. of multi-platform images, down to a manifest
+	for range 8 {
+		var m ociManifest
+		if err := json.Unmarshal(content, &m); err != nil {
+			return nil, fmt.Errorf("%s: invalid OCI image: %w", archive, err)
+		}
+		if len(m.Layers) > 0 || len(m.Manifests) == 0 {
+			layers := make([]string, 0, len(m.Layers))
+			for _, l := range m.Layers {
+				layers = append(layers, blobPath(l.Digest))
+			}
+			return layers, nil
+		}
+		if content, ok = meta[blobPath(m.Manifests[0].Digest)]; !ok {
+			return nil, fmt.Errorf("%s: invalid OCI image: missing manifest %s", archive, m.Manifests[0].Digest)
+		}
+	}
+	return nil, fmt.Errorf("%s: invalid OCI image: too deeply nested index", archive)
+}
+
+// add records a layer entry.
+func (l *layerEntries) add(hdr *tar.Header) {
+	name := cleanEntryName(hdr.Name)
+	base := path.Base(name)
+	switch {
+	case name == "":
+	case base == whiteoutOpaque:
+		l.opaques = append(l.opaques, path.Dir(name))
+	case strings.HasPrefix(base, whiteoutPrefix):
+		l.whiteouts = append(l.whiteouts, path.Join(path.Dir(name), base[len(whiteoutPrefix):]))
+	case hdr.Typeflag != tar.TypeDir:
+		l.files[name] = hdr.Typeflag == tar.TypeReg
+	}
+}
+
+// visibleFiles returns, for each layer, the regular files it contributes to
+// the root filesystem of the image: those neither replaced nor deleted by an
+// upper layer.
+func visibleFiles(layers []*layerEntries) []map[string]bool {
+	visible := make([]map[string]bool, len(layers))
+	// Paths that upper layers replaced with a file or deleted, along with
+	// anything below them
+	shadowed := make(map[string]bool)
+	// Directories whose content in lower layers is hidden
+	opaque := make(map[string]bool)
+
+	hidden := func(p string) bool {
+		if shadowed[p] {
+			return true
+		}
+		for d := path.Dir(p); ; d = path.Dir(d) {
+			if shadowed[d] || opaque[d] {
+				return true
+			}
+			if d == "." {
+				return false
+			}
+		}
+	}
+
+	for i := len(layers) - 1; i >= 0; i-- {
+		visible[i] = make(map[string]bool)
+		for p, regular := range layers[i].files {
+			if regular && !hidden(p) {
+				visible[i][p] = true
+			}
+		}
+		for p := range layers[i].files {
+			shadowed[p] = true
+		}
+		for _, p := range layers[i].whiteouts {
+			shadowed[p] = true
+		}
+		for _, p := range layers[i].opaques {
+			opaque[p] = true
+		}
+	}
+	return visible
+}
+
+// walkImage calls fn for each regular file of the root filesystem of the image
+// whose layers are given. The tarball is read once to apply the whiteouts of
+// the layers, then once more to stream the visible files.
+func walkImage(fsys fs.FS, archive string, layers []string, fn func(name string, r io.Reader)) error {
+	index := make(map[string]int, len(layers))
+	for i, l := range layers {
+		index[l] = i
+	}
+
+	eachLayer := func(fn func(layer int, tr *tar.Reader) error) error {
+		return walkTar(fsys, archive, func(hdr *tar.Header, r io.Reader) error {
+			i, ok := index[cleanEntryName(hdr.Name)]
+			if !ok {
+				return nil
+			}
+			lr, err := decompress(r)
+			if err != nil {
+				return fmt.Errorf("layer %s: %w", layers[i], err)
+			}
+			if err := fn(i, tar.NewReader(lr)); err != nil {
+				return fmt.Errorf("layer %s: %w", layers[i], err)
+			}
+			return nil
+		})
+	}
+
+	entries := make([]*layerEntries, len(layers))
+	for i := range entries {
+		entries[i] = &layerEntries{files: make(map[string]bool)}
+	}
+	err := eachLayer(func(layer int, tr *tar.Reader) error {
+		return eachTarEntry(tr, func(hdr *tar.Header, _ io.Reader) error {
+			entries[layer].add(hdr)
+			return nil
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	visible := visibleFiles(entries)
+	return eachLayer(func(layer int, tr *tar.Reader) error {
+		return eachTarEntry(tr, func(hdr *tar.Header, r io.Reader) error {
+			if name := cleanEntryName(hdr.Name); hdr.Typeflag == tar.TypeReg && visible[layer][name] {
+				fn(name, r)
+			}
+			return nil
+		})
+	})
+}
+
+// processEntry runs the extractors on a file of an archive. Its content is
+// only read in full when it has an extractor or looks like a shell script.
+func (c *Config) processEntry(archive, name string, r io.Reader, ignores map[string]bool, res ScanResult, excludes []ignoreList) {
+	name = cleanEntryName(name)
+	if name == "" || excludedPath(name, false, excludes) {
+		return
+	}
+	if !c.ShowHidden {
+		for _, part := range strings.Split(name, "/") {
+			if part[0] == '.' && !hookEntry(part) {
+				return
+			}
+		}
+	}
+
+	br := bufio.NewReader(r)
+	ext := c.getExtractor(name)
+	if ext == nil {
+		if !reShellExt.MatchString(name) {
+			head, _ := br.Peek(256)
+			line, _, _ := bytes.Cut(head, []byte("\n"))
+			if !reShebang.Match(line) {
+				return
+			}
+		}
+		ext = &ShellExtractor{}
+	}
+
+	content, err := io.ReadAll(br)
+	if err != nil {
+		return
+	}
+	if occs := c.processContent(content, ext, ignores); len(occs) > 0 {
+		res[archive+archiveSep+name] = occs
+	}
+}
+
+// scanArchive scans the files of a tar or zip archive, or the root filesystem
+// of a container image tarball, without extracting them.
+func (c *Config) scanArchive(fsys fs.FS, archive string, ignores map[string]bool, res ScanResult, matcher *ignoreMatcher) error {
+	archive = cleanPath(fsys, archive)
+	excludes := matcher.archiveLists()
+	process := func(name string, r io.Reader) {
+		c.processEntry(archive, name, r, ignores, res, excludes)
+	}
+
+	if strings.HasSuffix(archive, ".zip") {
+		return walkZip(fsys, archive, process)
+	}
+
+	layers, err := readImageLayers(fsys, archive)
+	if err != nil {
+		return err
+	}
+	if layers != nil {
+		return walkImage(fsys, archive, layers, process)
+	}
+
+	return walkTar(fsys, archive, func(hdr *tar.Header, r io.Reader) error {
+		process(hdr.Name, r)
+		return nil
+	})
+}
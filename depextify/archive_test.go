@@ -0,0 +1,161 @@
+package depextify
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type tarEntry struct {
+	name    string
+	content string
+	dir     bool
+	// link makes the entry a symlink to it.
+	link string
+	// mode defaults to 0755.
+	mode int64
+}
+
+func buildTar(t *testing.T, entries []tarEntry, gzipped bool) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	var gz *gzip.Writer
+	tw := tar.NewWriter(&buf)
+	if gzipped {
+		gz = gzip.NewWriter(&buf)
+		tw = tar.NewWriter(gz)
+	}
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e.name, Mode: 0755, Size: int64(len(e.content)), Typeflag: tar.TypeReg}
+		if e.mode != 0 {
+			hdr.Mode = e.mode
+		}
+		switch {
+		case e.dir:
+			hdr.Typeflag = tar.TypeDir
+		case e.link != "":
+			hdr.Typeflag = tar.TypeSymlink
+			hdr.Linkname = e.link
+		}
+		require.NoError(t, tw.WriteHeader(hdr))
+		_, err := tw.Write([]byte(e.content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	if gz != nil {
+		require.NoError(t, gz.Close())
+	}
+	return buf.Bytes()
+}
+
+func TestScan_Archives(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	t.Run("tar.gz", func(t *testing.T) {
+		archive := filepath.Join(tmpDir, "release.tar.gz")
+		require.NoError(t, os.WriteFile(archive, buildTar(t, []tarEntry{
+			{name: "./release/", dir: true},
+			{name: "./release/install.sh", content: "curl -fsSL example.com\n"},
+			{name: "./release/Makefile", content: "all:\n\tgo build\n"},
+			{name: "./release/bin/setup", content: "#!/bin/bash\njq . config.json\n"},
+			{name: "./release/README.txt", content: "make install\n"},
+			{name: "./release/.ci/test.sh", content: "shellcheck *.sh\n"},
+		}, true), 0600))
+
+		config := &Config{}
+		res, err := config.Scan(archive)
+		require.NoError(t, err)
+		require.True(t, config.IsDirectory)
+
+		require.Equal(t, []Occurrence{{Line: 1, Col: 1, Len: 4, FullLine: "curl -fsSL example.com"}}, res[archive+"!/release/install.sh"]["curl"])
+		require.Contains(t, res[archive+"!/release/Makefile"], "go")
+		require.Contains(t, res[archive+"!/release/bin/setup"], "jq")
+		require.Len(t, res, 3)
+	})
+
+	t.Run("zip", func(t *testing.T) {
+		archive := filepath.Join(tmpDir, "scripts.zip")
+		var buf bytes.Buffer
+		zw := zip.NewWriter(&buf)
+		w, err := zw.Create("scripts/deploy.sh")
+		require.NoError(t, err)
+		_, err = w.Write([]byte("rsync -a dist/ host:\n"))
+		require.NoError(t, err)
+		require.NoError(t, zw.Close())
+		require.NoError(t, os.WriteFile(archive, buf.Bytes(), 0600))
+
+		res, err := (&Config{}).Scan(archive)
+		require.NoError(t, err)
+		require.Contains(t, res[archive+"!/scripts/deploy.sh"], "rsync")
+	})
+
+	t.Run("docker save", func(t *testing.T) {
+		base := buildTar(t, []tarEntry{
+			{name: "usr/local/bin/", dir: true},
+			{name: "usr/local/bin/setup.sh", content: "curl example.com\n"},
+			{name: "etc/old.sh", content: "wget example.com\n"},
+			{name: "opt/app/run.sh", content: "jq . x\n"},
+			{name: "opt/keep.sh", content: "git pull\n"},
+		}, false)
+		top := buildTar(t, []tarEntry{
+			{name: "usr/local/bin/setup.sh", content: "\n\naria2c example.com\n"},
+			{name: "etc/.wh.old.sh"},
+			{name: "opt/app/.wh..wh..opq"},
+			{name: "opt/app/start.sh", content: "make run\n"},
+		}, true)
+		image := buildTar(t, []tarEntry{
+			// Layers are not necessarily stored in order
+			{name: "top/layer.tar", content: string(top)},
+			{name: "base/layer.tar", content: string(base)},
+			{name: "manifest.json", content: `[{"Config":"config.json","RepoTags":["app:latest"],"Layers":["base/layer.tar","top/layer.tar"]}]`},
+		}, false)
+		archive := filepath.Join(tmpDir, "image.tar")
+		require.NoError(t, os.WriteFile(archive, image, 0600))
+
+		res, err := (&Config{}).Scan(archive)
+		require.NoError(t, err)
+
+		require.Equal(t, []Occurrence{{Line: 3, Col: 1, Len: 6, FullLine: "aria2c example.com"}}, res[archive+"!/usr/local/bin/setup.sh"]["aria2c"])
+		require.NotContains(t, res[archive+"!/usr/local/bin/setup.sh"], "curl")
+		require.Contains(t, res[archive+"!/opt/keep.sh"], "git")
+		require.Contains(t, res[archive+"!/opt/app/start.sh"], "make")
+		require.NotContains(t, res, archive+"!/etc/old.sh")
+		require.NotContains(t, res, archive+"!/opt/app/run.sh")
+		require.Len(t, res, 3)
+	})
+
+	t.Run("OCI layout", func(t *testing.T) {
+		layer := buildTar(t, []tarEntry{
+			{name: "entrypoint.sh", content: "tini -- app\n"},
+		}, true)
+		image := buildTar(t, []tarEntry{
+			{name: "oci-layout", content: `{"imageLayoutVersion":"1.0.0"}`},
+			{name: "index.json", content: `{"schemaVersion":2,"manifests":[{"digest":"sha256:aaa"}]}`},
+			{name: "blobs/sha256/aaa", content: `{"schemaVersion":2,"manifests":[{"digest":"sha256:bbb"}]}`},
+			{name: "blobs/sha256/bbb", content: `{"schemaVersion":2,"config":{"digest":"sha256:ccc"},"layers":[{"digest":"sha256:ddd"}]}`},
+			{name: "blobs/sha256/ccc", content: `{"architecture":"amd64"}`},
+			{name: "blobs/sha256/ddd", content: string(layer)},
+		}, false)
+		archive := filepath.Join(tmpDir, "oci.tar")
+		require.NoError(t, os.WriteFile(archive, image, 0600))
+
+		res, err := (&Config{}).Scan(archive)
+		require.NoError(t, err)
+		require.Contains(t, res[archive+"!/entrypoint.sh"], "tini")
+		require.Len(t, res, 1)
+	})
+
+	t.Run("corrupt", func(t *testing.T) {
+		archive := filepath.Join(tmpDir, "corrupt.tar.gz")
+		require.NoError(t, os.WriteFile(archive, []byte{0x1f, 0x8b, 0, 0}, 0600))
+
+		_, err := (&Config{}).Scan(archive)
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,180 @@
+package depextify
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+type (
+	// Baseline is a snapshot of the commands of each file, compared to later
+	// scans to find the commands added and removed. It is encoded like the
+	// JSON output without -count and -pos.
+	Baseline map[string][]string
+
+	// BaselineDiff is what changed since a baseline.
+	BaselineDiff struct {
+		// Added are the commands no file of the baseline uses.
+		Added []string
+		// Removed are the commands of the baseline no file uses anymore.
+		Removed []string
+		Files   []FileDiff
+	}
+
+	// FileDiff is what changed in a file since a baseline.
+	FileDiff struct {
+		Path    string
+		Added   []string `json:",omitempty" yaml:",omitempty"`
+		Removed []string `json:",omitempty" yaml:",omitempty"`
+	}
+)
+
+// Baseline returns the snapshot of r.
+func (r ScanResult) Baseline() Baseline {
+	b := make(Baseline, len(r))
+	for path, cmds := range r {
+		b[path] = slices.Sorted(maps.Keys(cmds))
+	}
+	return b
+}
+
+// ReadBaseline reads a baseline file.
+func ReadBaseline(path string) (Baseline, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var b Baseline
+	if err := json.Unmarshal(content, &b); err != nil {
+		return nil, fmt.Errorf("%s: invalid baseline: %w", path, err)
+	}
+	return b, nil
+}
+
+// WriteFile writes the baseline to path.
+func (b Baseline) WriteFile(path string) error {
+	content, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(content, '\n'), 0o644)
+}
+
+// commands returns the set of the commands of the baseline.
+func (b Baseline) commands() map[string]bool {
+	set := make(map[string]bool)
+	for _, cmds := range b {
+		for _, cmd := range cmds {
+			set[cmd] = true
+		}
+	}
+	return set
+}
+
+// setDiff returns the commands of a not in b, sorted.
+func setDiff(a, b map[string]bool) []string {
+	diff := []string{}
+	for cmd := range a {
+		if !b[cmd] {
+			diff = append(diff, cmd)
+		}
+	}
+	slices.Sort(diff)
+	return diff
+}
+
+// Diff returns what changed from the baseline b to current, sorted by path.
+func (b Baseline) Diff(current Baseline) BaselineDiff {
+	d := BaselineDiff{Files: []FileDiff{}}
+	before, after := b.commands(), current.commands()
+	d.Added = setDiff(after, before)
+	d.Removed = setDiff(before, after)
+
+	paths := slices.Collect(maps.Keys(b))
+	for path := range current {
+		if _, ok := b[path]; !ok {
+			paths = append(paths, path)
+		}
+	}
+	slices.Sort(paths)
+
+	for _, path := range paths {
+		before, after := toSet(b[path]), toSet(current[path])
+		fd := FileDiff{Path: path, Added: setDiff(after, before), Removed: setDiff(before, after)}
+		if len(fd.Added) > 0 || len(fd.Removed) > 0 {
+			d.Files = append(d.Files, fd)
+		}
+	}
+	return d
+}
+
+// Format returns the commands added ("+") and removed ("-") in each file,
+// then the commands added and removed across all files.
+func (d BaselineDiff) Format(c *Config) string {
+	var sb strings.Builder
+
+	colon := ":"
+	if c.UseColor {
+		colon = colorYellow + ":" + colorReset
+	}
+	line := func(sign, color, cmd string) {
+		s := sign + " " + cmd
+		if c.UseColor {
+			s = color + s + colorReset
+		}
+		fmt.Fprintf(&sb, "  %s\n", s)
+	}
+
+	for _, fd := range d.Files {
+		path := fd.Path
+		if c.UseColor {
+			path = colorCyan + path + colorReset
+		}
+		fmt.Fprintf(&sb, "%s%s\n", path, colon)
+		for _, cmd := range fd.Added {
+			line("+", colorGreen, cmd)
+		}
+		for _, cmd := range fd.Removed {
+			line("-", colorRed, cmd)
+		}
+	}
+
+	summary := func(label string, cmds []string) {
+		if len(cmds) == 0 {
+			return
+		}
+		if c.UseColor {
+			label = colorBold + label + colorReset
+		}
+		fmt.Fprintf(&sb, "%s%s %s\n", label, colon, strings.Join(cmds, ", "))
+	}
+	if len(d.Added) > 0 || len(d.Removed) > 0 {
+		sb.WriteString("\n")
+	}
+	summary("new commands", d.Added)
+	summary("removed commands", d.Removed)
+	return sb.String()
+}
+
+// JSON returns the JSON encoding of the diff.
+func (d BaselineDiff) JSON() (string, error) {
+	b, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// YAML returns the YAML encoding of the diff.
+func (d BaselineDiff) YAML() (string, error) {
+	b, err := yaml.Marshal(d)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
@@ -0,0 +1,88 @@
+package depextify
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBaseline(t *testing.T) {
+	res := ScanResult{
+		"deploy.sh": {"aws": {{Line: 1}}, "jq": {{Line: 2}, {Line: 3}}},
+		"Makefile":  {"docker": {{Line: 1}}},
+	}
+
+	path := filepath.Join(t.TempDir(), "deps.json")
+	require.NoError(t, res.Baseline().WriteFile(path))
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "{\n  \"Makefile\": [\n    \"docker\"\n  ],\n  \"deploy.sh\": [\n    \"aws\",\n    \"jq\"\n  ]\n}\n", string(content))
+
+	// The JSON output is a baseline too
+	out, err := res.JSON(&Config{})
+	require.NoError(t, err)
+	require.JSONEq(t, out, string(content))
+
+	baseline, err := ReadBaseline(path)
+	require.NoError(t, err)
+	require.Equal(t, res.Baseline(), baseline)
+
+	require.NoError(t, os.WriteFile(path, []byte("[]"), 0o644))
+	_, err = ReadBaseline(path)
+	require.Error(t, err)
+	_, err = ReadBaseline(filepath.Join(t.TempDir(), "missing.json"))
+	require.Error(t, err)
+}
+
+func TestBaseline_Diff(t *testing.T) {
+	baseline := Baseline{
+		"deploy.sh": {"aws", "jq"},
+		"Makefile":  {"docker"},
+		"old.sh":    {"aws"},
+	}
+
+	t.Run("unchanged", func(t *testing.T) {
+		d := baseline.Diff(baseline)
+		require.Empty(t, d.Added)
+		require.Empty(t, d.Removed)
+		require.Empty(t, d.Files)
+		require.Empty(t, d.Format(&Config{}))
+	})
+
+	t.Run("added and removed", func(t *testing.T) {
+		d := baseline.Diff(Baseline{
+			"deploy.sh": {"jq", "terraform"},
+			"Makefile":  {"docker"},
+			"new.sh":    {"jq"},
+		})
+		require.Equal(t, []string{"terraform"}, d.Added)
+		require.Equal(t, []string{"aws"}, d.Removed)
+		require.Equal(t, []FileDiff{
+			{Path: "deploy.sh", Added: []string{"terraform"}, Removed: []string{"aws"}},
+			{Path: "new.sh", Added: []string{"jq"}, Removed: []string{}},
+			{Path: "old.sh", Added: []string{}, Removed: []string{"aws"}},
+		}, d.Files)
+
+		require.Equal(t, `deploy.sh:
+  + terraform
+  - aws
+new.sh:
+  + jq
+old.sh:
+  - aws
+
+new commands: terraform
+removed commands: aws
+`, d.Format(&Config{}))
+	})
+
+	t.Run("moved", func(t *testing.T) {
+		// Commands moving between files are neither new nor removed
+		d := baseline.Diff(Baseline{"deploy.sh": {"aws", "docker", "jq"}})
+		require.Empty(t, d.Added)
+		require.Empty(t, d.Removed)
+		require.Len(t, d.Files, 3)
+	})
+}
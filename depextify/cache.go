@@ -0,0 +1,203 @@
+package depextify
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"slices"
+	"sync"
+	"sync/atomic"
+)
+
+// cacheFormat is bumped whenever cache entries change shape.
+const cacheFormat = 2
+
+const modulePath = "github.com/nymphium/depextify"
+
+// executableHash is the SHA-256 of the running executable, hashed into cache
+// keys: Version stays "(devel)" for builds without VCS information, e.g. by
+// Nix, so it alone misses changes to the extractors. It is "" if the
+// executable cannot be read.
+var executableHash = sync.OnceValue(func() string {
+	path, err := os.Executable()
+	if err != nil {
+		return ""
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer func() { _ = f.Close() }()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(h.Sum(nil))
+})
+
+type (
+	// CacheStats counts the files whose results were found in the cache
+	// (Hits) or had to be extracted (Misses).
+	CacheStats struct {
+		Hits   int64
+		Misses int64
+	}
+
+	// resultCache stores the occurrences found in file contents on disk, so
+	// unchanged files are not parsed again by later scans.
+	resultCache struct {
+		dir string
+		// prefix is hashed into every key: it changes with the version of
+		// depextify and the settings the occurrences depend on.
+		prefix []byte
+
+		hits   atomic.Int64
+		misses atomic.Int64
+	}
+)
+
+// Version returns the version of depextify the binary was built with, or
+// "(devel)" with the VCS revision for a local build.
+func Version() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "(unknown)"
+	}
+
+	version := info.Main.Version
+	if info.Main.Path != modulePath {
+		// Used as a library
+		for _, dep := range info.Deps {
+			if dep.Path == modulePath {
+				version = dep.Version
+			}
+		}
+	}
+
+	if version == "" || version == "(devel)" {
+		version = "(devel)"
+		var revision, modified string
+		for _, s := range info.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				revision = s.Value
+			case "vcs.modified":
+				modified = s.Value
+			}
+		}
+		if revision != "" {
+			version += " " + revision
+			if modified == "true" {
+				version += "-dirty"
+			}
+		}
+	}
+	return version
+}
+
+// openCache sets up the cache of c, if CacheDir is set.
+func (c *Config) openCache() error {
+	if c.CacheDir == "" {
+		c.cache = nil
+		return nil
+	}
+	if err := os.MkdirAll(c.CacheDir, 0o755); err != nil {
+		return fmt.Errorf("cache: %w", err)
+	}
+
+	ignores := slices.Clone(c.ExtraIgnores)
+	slices.Sort(ignores)
+	prefix, err := json.Marshal(struct {
+		Format      int
+		Version     string
+		Executable  string
+		NoBuiltins  bool
+		NoCoreutils bool
+		NoCommon    bool
+		Ignores     []string
+	}{cacheFormat, Version(), executableHash(), c.NoBuiltins, c.NoCoreutils, c.NoCommon, ignores})
+	if err != nil {
+		return err
+	}
+
+	// Keep counting in the same cache across scans
+	if c.cache != nil && c.cache.dir == c.CacheDir {
+		c.cache.prefix = prefix
+		return nil
+	}
+	c.cache = &resultCache{dir: c.CacheDir, prefix: prefix}
+	return nil
+}
+
+// CacheStats returns the cache hits and misses of the scans done with c so far.
+func (c *Config) CacheStats() CacheStats {
+	if c.cache == nil {
+		return CacheStats{}
+	}
+	return CacheStats{Hits: c.cache.hits.Load(), Misses: c.cache.misses.Load()}
+}
+
+// key returns the cache key of the occurrences ext finds in content.
+func (rc *resultCache) key(ext Extractor, content []byte) string {
+	h := sha256.New()
+	h.Write(rc.prefix)
+	// Extractor type and settings, e.g. *depextify.NixExtractor{"Inputs":true}
+	extConfig, _ := json.Marshal(ext)
+	fmt.Fprintf(h, "\x00%T%s\x00", ext, extConfig)
+	h.Write(content)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (rc *resultCache) path(key string) string {
+	return filepath.Join(rc.dir, key[:2], key+".json")
+}
+
+// get returns the occurrences stored for key.
+func (rc *resultCache) get(key string) (map[string][]Occurrence, bool) {
+	content, err := os.ReadFile(rc.path(key))
+	if err != nil {
+		rc.misses.Add(1)
+		return nil, false
+	}
+	var occs map[string][]Occurrence
+	if err := json.Unmarshal(content, &occs); err != nil {
+		rc.misses.Add(1)
+		return nil, false
+	}
+	rc.hits.Add(1)
+	return occs, true
+}
+
+// put stores the occurrences for key. The cache is best effort, so failures
+// are ignored.
+func (rc *resultCache) put(key string, occs map[string][]Occurrence) {
+	content, err := json.Marshal(occs)
+	if err != nil {
+		return
+	}
+	path := rc.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return
+	}
+	// Write to a temporary file first, so concurrent scans never read a
+	// partial entry.
+	tmp, err := os.CreateTemp(filepath.Dir(path), key+".*.tmp")
+	if err != nil {
+		return
+	}
+	_, err = tmp.Write(content)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+	}
+}
@@ -0,0 +1,88 @@
+package depextify
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestScan_Cache(t *testing.T) {
+	tmpDir := t.TempDir()
+	cacheDir := filepath.Join(t.TempDir(), "cache")
+	script := filepath.Join(tmpDir, "build.sh")
+	require.NoError(t, os.WriteFile(script, []byte("curl example.com\ngrep x y\n"), 0600))
+	makefile := filepath.Join(tmpDir, "Makefile")
+	require.NoError(t, os.WriteFile(makefile, []byte("all:\n\tgo build\n"), 0600))
+
+	config := &Config{CacheDir: cacheDir, ShowPos: true}
+	first, err := config.Scan(tmpDir)
+	require.NoError(t, err)
+	require.Equal(t, CacheStats{Hits: 0, Misses: 2}, config.CacheStats())
+
+	t.Run("unchanged files are cached", func(t *testing.T) {
+		config := &Config{CacheDir: cacheDir, ShowPos: true}
+		second, err := config.Scan(tmpDir)
+		require.NoError(t, err)
+		require.Equal(t, first, second)
+		require.Equal(t, CacheStats{Hits: 2, Misses: 0}, config.CacheStats())
+	})
+
+	t.Run("changed file", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(script, []byte("wget example.com\n"), 0600))
+		config := &Config{CacheDir: cacheDir}
+		res, err := config.Scan(tmpDir)
+		require.NoError(t, err)
+		require.Contains(t, res[script], "wget")
+		require.NotContains(t, res[script], "curl")
+		require.Equal(t, CacheStats{Hits: 1, Misses: 1}, config.CacheStats())
+	})
+
+	t.Run("filtering settings", func(t *testing.T) {
+		config := &Config{CacheDir: cacheDir, NoCommon: true}
+		res, err := config.Scan(tmpDir)
+		require.NoError(t, err)
+		require.Equal(t, CacheStats{Hits: 0, Misses: 2}, config.CacheStats())
+
+		config.NoCommon = false
+		config.ExtraIgnores = []string{"go"}
+		res, err = config.Scan(tmpDir)
+		require.NoError(t, err)
+		require.NotContains(t, res, makefile)
+		require.Equal(t, CacheStats{Hits: 0, Misses: 4}, config.CacheStats())
+	})
+
+	t.Run("extractor settings", func(t *testing.T) {
+		nix := filepath.Join(tmpDir, "default.nix")
+		require.NoError(t, os.WriteFile(nix, []byte("{ buildInputs = [ jq ]; }\n"), 0600))
+
+		res, err := (&Config{CacheDir: cacheDir}).Scan(nix)
+		require.NoError(t, err)
+		require.Empty(t, res)
+
+		res, err = (&Config{CacheDir: cacheDir, NixInputs: true}).Scan(nix)
+		require.NoError(t, err)
+		require.Contains(t, res[nix], "jq")
+	})
+
+	t.Run("executable", func(t *testing.T) {
+		require.NotEmpty(t, executableHash())
+		config := &Config{CacheDir: cacheDir}
+		require.NoError(t, config.openCache())
+		key := config.cache.key(&ShellExtractor{}, []byte("curl example.com\n"))
+
+		orig := executableHash
+		t.Cleanup(func() { executableHash = orig })
+		executableHash = func() string { return "rebuilt" }
+		require.NoError(t, config.openCache())
+		require.NotEqual(t, key, config.cache.key(&ShellExtractor{}, []byte("curl example.com\n")))
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		config := &Config{}
+		_, err := config.Scan(tmpDir)
+		require.NoError(t, err)
+		require.Equal(t, CacheStats{}, config.CacheStats())
+	})
+}
@@ -0,0 +1,150 @@
+package depextify
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+type (
+	// CommandCheck is whether a command of a scan can be run.
+	CommandCheck struct {
+		Command string
+		Found   bool
+		// Path is where the command was found.
+		Path string `json:",omitempty" yaml:",omitempty"`
+		// Target is what Path links to, when checking a root filesystem.
+		Target string `json:",omitempty" yaml:",omitempty"`
+		// Builtin is set for shell built-ins, which are always found.
+		Builtin bool `json:",omitempty" yaml:",omitempty"`
+		// Files are the files using the command.
+		Files []string
+	}
+
+	// Checks are the results of checking the commands of a scan, sorted by
+	// command.
+	Checks []CommandCheck
+)
+
+// checkCommands checks every command of r with lookup, which returns where
+// the command is found and what it links to.
+func (r ScanResult) checkCommands(lookup func(cmd string) (string, string, bool)) Checks {
+	files := make(map[string][]string)
+	for path, cmds := range r {
+		for cmd := range cmds {
+			files[cmd] = append(files[cmd], path)
+		}
+	}
+
+	checks := make(Checks, 0, len(files))
+	for _, cmd := range slices.Sorted(maps.Keys(files)) {
+		check := CommandCheck{Command: cmd, Files: files[cmd]}
+		slices.Sort(check.Files)
+		if builtins[cmd] {
+			check.Found = true
+			check.Builtin = true
+		} else {
+			check.Path, check.Target, check.Found = lookup(cmd)
+		}
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+// Check resolves the commands of r the way exec.LookPath does, searching the
+// directories of pathList, a list in the format of $PATH. A command
+// containing a slash is looked up as is.
+func (r ScanResult) Check(pathList string) Checks {
+	dirs := filepath.SplitList(pathList)
+	return r.checkCommands(func(cmd string) (string, string, bool) {
+		if strings.Contains(cmd, "/") {
+			return cmd, "", isExecutable(cmd)
+		}
+		for _, dir := range dirs {
+			if dir == "" {
+				// An empty element is the current directory
+				dir = "."
+			}
+			p := filepath.Join(dir, cmd)
+			if isExecutable(p) {
+				return p, "", true
+			}
+		}
+		return "", "", false
+	})
+}
+
+// isExecutable reports whether path is a file anyone may execute.
+func isExecutable(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir() && info.Mode()&0o111 != 0
+}
+
+// Missing returns the commands that were not found.
+func (cs Checks) Missing() []string {
+	var missing []string
+	for _, c := range cs {
+		if !c.Found {
+			missing = append(missing, c.Command)
+		}
+	}
+	return missing
+}
+
+// Format returns a line per command, telling where it was found or the files
+// needing it when it is missing.
+func (cs Checks) Format(c *Config) string {
+	var sb strings.Builder
+
+	width := 0
+	for _, check := range cs {
+		width = max(width, len(check.Command))
+	}
+
+	for _, check := range cs {
+		status, detail := "ok", check.Path
+		color := colorGreen
+		switch {
+		case check.Builtin:
+			detail = "shell built-in"
+		case check.Target != "":
+			detail += " -> " + check.Target
+		case !check.Found:
+			status, color = "missing", colorRed
+			detail = "used in " + strings.Join(check.Files, ", ")
+		}
+
+		cmd := fmt.Sprintf("%-*s", width, check.Command)
+		status = fmt.Sprintf("%-7s", status)
+		if c.UseColor {
+			cmd = colorBold + cmd + colorReset
+			status = color + status + colorReset
+		}
+		fmt.Fprintf(&sb, "%s  %s  %s\n", status, cmd, detail)
+	}
+	return sb.String()
+}
+
+// JSON returns the JSON encoding of the checks.
+func (cs Checks) JSON() (string, error) {
+	b, err := json.MarshalIndent(cs, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// YAML returns the YAML encoding of the checks.
+func (cs Checks) YAML() (string, error) {
+	b, err := yaml.Marshal(cs)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
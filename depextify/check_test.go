@@ -0,0 +1,54 @@
+package depextify
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResult_Check(t *testing.T) {
+	bin1, bin2 := t.TempDir(), t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(bin1, "jq"), []byte("#!/bin/sh\n"), 0o755))
+	// Not executable, so bin2/aws is found instead
+	require.NoError(t, os.WriteFile(filepath.Join(bin1, "aws"), []byte("#!/bin/sh\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(bin2, "aws"), []byte("#!/bin/sh\n"), 0o755))
+	require.NoError(t, os.Mkdir(filepath.Join(bin2, "terraform"), 0o755))
+	local := filepath.Join(bin2, "local.sh")
+	require.NoError(t, os.WriteFile(local, []byte("#!/bin/sh\n"), 0o755))
+
+	res := ScanResult{
+		"deploy.sh": {
+			"jq":        {{Line: 1}},
+			"aws":       {{Line: 2}},
+			"terraform": {{Line: 3}},
+			"cd":        {{Line: 4}},
+			local:       {{Line: 5}},
+		},
+		"Makefile": {"terraform": {{Line: 1}}},
+	}
+
+	checks := res.Check(strings.Join([]string{bin1, bin2}, string(os.PathListSeparator)))
+	require.Equal(t, Checks{
+		{Command: local, Found: true, Path: local, Files: []string{"deploy.sh"}},
+		{Command: "aws", Found: true, Path: filepath.Join(bin2, "aws"), Files: []string{"deploy.sh"}},
+		{Command: "cd", Found: true, Builtin: true, Files: []string{"deploy.sh"}},
+		{Command: "jq", Found: true, Path: filepath.Join(bin1, "jq"), Files: []string{"deploy.sh"}},
+		{Command: "terraform", Files: []string{"Makefile", "deploy.sh"}},
+	}, checks)
+	require.Equal(t, []string{"terraform"}, checks.Missing())
+
+	out := checks[2:].Format(&Config{})
+	require.Equal(t, "ok       cd         shell built-in\nok       jq         "+filepath.Join(bin1, "jq")+"\nmissing  terraform  used in Makefile, deploy.sh\n", out)
+
+	t.Run("current directory", func(t *testing.T) {
+		t.Chdir(bin1)
+		checks := ScanResult{"a.sh": {"jq": {{Line: 1}}}}.Check(string(os.PathListSeparator) + bin2)
+		require.True(t, checks[0].Found)
+		require.Equal(t, "jq", checks[0].Path)
+
+		require.Equal(t, []string{"jq"}, ScanResult{"a.sh": {"jq": {{Line: 1}}}}.Check(bin2).Missing())
+	})
+}
@@ -3,12 +3,15 @@ package depextify
 import (
 	"bufio"
 	"io"
+	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
 	"regexp"
+	"runtime"
 	"strings"
+	"sync"
 
-	ignore "github.com/sabhiram/go-gitignore"
 	"mvdan.cc/sh/v3/syntax"
 )
 
@@ -20,6 +23,7 @@ type (
 		NoCommon     bool     `yaml:"no_common"`
 		ShowHidden   bool     `yaml:"show_hidden"`
 		ExtraIgnores []string `yaml:"ignores"`
+		NixInputs    bool     `yaml:"nix_inputs"`
 
 		ShowCount   bool   `yaml:"show_count"`
 		ShowPos     bool   `yaml:"show_pos"`
@@ -28,8 +32,56 @@ type (
 		StyleName   string `yaml:"style"`
 		IsDirectory bool   `yaml:"-"`
 		Format      string `yaml:"format"`
+		// GroupBy is GroupByFile or GroupByCommand, and SortBy how commands
+		// are sorted when grouped by command: SortByCount or SortByName.
+		GroupBy string `yaml:"group_by"`
+		SortBy  string `yaml:"sort_by"`
 
 		Excludes []string `yaml:"excludes"`
+
+		// Denies lists commands that must not be used. They are still
+		// reported, in the "denied" category.
+		Denies []string `yaml:"denies"`
+		// Policy restricts the commands of files, see PolicyRule.
+		Policy []PolicyRule `yaml:"policy"`
+		// PolicyDir is the directory the Paths of the policy are relative
+		// to, usually that of the config file; the current directory if
+		// empty.
+		PolicyDir string `yaml:"-"`
+
+		// Packages maps commands to the packages providing them, for SBOMs.
+		// A value starting with "pkg:" is a package URL.
+		Packages map[string]string `yaml:"packages"`
+
+		// PackageDB overrides the built-in database of packages providing
+		// commands used to generate manifests: ecosystem -> {command: package}.
+		// An empty package means the command needs none.
+		PackageDB map[string]map[string]string `yaml:"package_db"`
+		// NoGitignore disables .gitignore and .git/info/exclude; only
+		// .depextifyignore files and Excludes are applied then.
+		NoGitignore bool `yaml:"no_gitignore"`
+
+		// Jobs is the number of files processed concurrently while scanning a
+		// directory. Zero means GOMAXPROCS.
+		Jobs int `yaml:"jobs"`
+
+		// CacheDir, if set, is where the results of files are cached between
+		// scans, keyed by their content.
+		CacheDir string `yaml:"cache_dir"`
+		cache    *resultCache
+
+		// GitTracked restricts the scan to the files tracked by git.
+		GitTracked bool `yaml:"git_tracked"`
+		// Since restricts the scan to the files changed since a git
+		// revision, including those not committed yet.
+		Since string `yaml:"-"`
+		// Rev scans the tree of a git revision, read from the repository of
+		// the working directory, instead of the working tree.
+		Rev string `yaml:"-"`
+
+		// StdinFilename is the name content read from stdin is reported as,
+		// which also selects its extractor.
+		StdinFilename string `yaml:"-"`
 	}
 
 	// Occurrence represents a single occurrence of a command.
@@ -38,6 +90,12 @@ type (
 		Col      int
 		Len      int
 		FullLine string
+		// Context locates the occurrence within the file when the line number
+		// alone is not meaningful, e.g. "cell 3:2" in a Jupyter notebook.
+		Context string `json:",omitempty" yaml:",omitempty"`
+		// PipedTo is the command the output of the occurrence is piped
+		// into, e.g. "sh" for curl in "curl -fsSL url | sh".
+		PipedTo string `json:",omitempty" yaml:",omitempty"`
 	}
 
 	// ScanResult maps filename to its command occurrences: filename -> {cmd: []Occurrence}
@@ -49,6 +107,45 @@ var (
 	reShebang  = regexp.MustCompile(`^#!\s*/.*(sh|bash|zsh|ksh)`)
 )
 
+// osFS is the fs.FS of the OS filesystem that Scan reads from. Unlike
+// os.DirFS, it takes native paths, absolute or relative to the working
+// directory, so results keep the paths given to Scan.
+type osFS struct{}
+
+func (osFS) Open(name string) (fs.File, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (osFS) Stat(name string) (fs.FileInfo, error) { return os.Stat(name) }
+
+func (osFS) ReadDir(name string) ([]fs.DirEntry, error) { return os.ReadDir(name) }
+
+func (osFS) ReadFile(name string) ([]byte, error) { return os.ReadFile(name) }
+
+func (osFS) Lstat(name string) (fs.FileInfo, error) { return os.Lstat(name) }
+
+func (osFS) ReadLink(name string) (string, error) { return os.Readlink(name) }
+
+// joinPath joins path elements into a path of fsys.
+func joinPath(fsys fs.FS, elem ...string) string {
+	if _, ok := fsys.(osFS); ok {
+		return filepath.Join(elem...)
+	}
+	return path.Join(elem...)
+}
+
+// cleanPath returns the shortest name of a path of fsys.
+func cleanPath(fsys fs.FS, p string) string {
+	if _, ok := fsys.(osFS); ok {
+		return filepath.Clean(p)
+	}
+	return path.Clean(p)
+}
+
 func toInt(u uint) int {
 	if u > uint(^uint(0)>>1) {
 		return 0
@@ -74,9 +171,18 @@ func collectLocalFuncs(file *syntax.File) map[string]bool {
 // - not starting with '-'
 func collectCommands(file *syntax.File, localFuncs map[string]bool) map[string][]posInfo {
 	commands := make(map[string][]posInfo)
+	// The commands calls are piped into; pipelines are walked before the
+	// calls they are made of.
+	pipes := make(map[*syntax.CallExpr]string)
 
 	syntax.Walk(file, func(node syntax.Node) bool {
 		switch x := node.(type) {
+		case *syntax.BinaryCmd:
+			if x.Op == syntax.Pipe || x.Op == syntax.PipeAll {
+				if from, to := pipeEnd(x.X, true), pipeEnd(x.Y, false); from != nil && to != nil {
+					pipes[from] = pipedCommand(to)
+				}
+			}
 		case *syntax.CallExpr:
 			if len(x.Args) > 0 && len(x.Args[0].Parts) == 1 {
 				if part, ok := x.Args[0].Parts[0].(*syntax.Lit); ok {
@@ -87,6 +193,7 @@ func collectCommands(file *syntax.File, localFuncs map[string]bool) map[string][
 							line: x.Pos().Line(),
 							col:  x.Pos().Col(),
 							len:  uint(len(cmd)),
+							pipe: pipes[x],
 						})
 					}
 				}
@@ -98,6 +205,47 @@ func collectCommands(file *syntax.File, localFuncs map[string]bool) map[string][
 	return commands
 }
 
+// pipeEnd returns the last call of the pipeline s, or the first one if last
+// is false.
+func pipeEnd(s *syntax.Stmt, last bool) *syntax.CallExpr {
+	switch x := s.Cmd.(type) {
+	case *syntax.CallExpr:
+		return x
+	case *syntax.BinaryCmd:
+		if x.Op != syntax.Pipe && x.Op != syntax.PipeAll {
+			return nil
+		}
+		if last {
+			return pipeEnd(x.Y, true)
+		}
+		return pipeEnd(x.X, false)
+	}
+	return nil
+}
+
+// pipeWrappers run the command given as their arguments.
+var pipeWrappers = map[string]bool{"sudo": true, "doas": true, "env": true, "exec": true, "command": true}
+
+// pipedCommand returns the command call runs, looking through wrappers like
+// sudo, or "" if it is not a literal.
+func pipedCommand(call *syntax.CallExpr) string {
+	wrapped := false
+	for _, arg := range call.Args {
+		word := arg.Lit()
+		switch {
+		case word == "":
+			return ""
+		case wrapped && (strings.HasPrefix(word, "-") || strings.Contains(word, "=")):
+			// Options of the wrapper, or variables env sets
+		case pipeWrappers[word]:
+			wrapped = true
+		default:
+			return word
+		}
+	}
+	return ""
+}
+
 // Do analyzes the given shell script file and returns a map of command names to their positions.
 func Do(f *os.File) (map[string][]posInfo, error) {
 	content, err := io.ReadAll(f)
@@ -107,12 +255,12 @@ func Do(f *os.File) (map[string][]posInfo, error) {
 	return analyzeShellCode(string(content))
 }
 
-func isShellFile(path string) bool {
+func isShellFile(fsys fs.FS, path string) bool {
 	if reShellExt.MatchString(path) {
 		return true
 	}
 
-	f, err := os.Open(path)
+	f, err := fsys.Open(path)
 	if err != nil {
 		return false
 	}
@@ -127,19 +275,30 @@ func isShellFile(path string) bool {
 	return reShebang.MatchString(string(line))
 }
 
+// hides reports whether the report leaves cmd out.
+func (c *Config) hides(cmd string, ignores map[string]bool) bool {
+	return (c.NoBuiltins && builtins[cmd]) || (c.NoCoreutils && coreutils[cmd]) || (c.NoCommon && common[cmd]) || ignores[cmd]
+}
+
 func (c *Config) calculateFileOccurrences(cmdPositions map[string][]posInfo, lines []string, ignores map[string]bool) map[string][]Occurrence {
 	fileOccs := make(map[string][]Occurrence)
 	for cmd, ps := range cmdPositions {
-		if (c.NoBuiltins && builtins[cmd]) || (c.NoCoreutils && coreutils[cmd]) || (c.NoCommon && common[cmd]) || ignores[cmd] {
+		if c.hides(cmd, ignores) {
 			continue
 		}
 		for _, p := range ps {
 			if p.line > 0 && p.line <= uint(len(lines)) {
+				fullLine := lines[p.line-1]
+				if p.text != "" {
+					fullLine = p.text
+				}
 				fileOccs[cmd] = append(fileOccs[cmd], Occurrence{
 					Line:     toInt(p.line),
 					Col:      toInt(p.col),
 					Len:      toInt(p.len),
-					FullLine: lines[p.line-1],
+					FullLine: fullLine,
+					Context:  p.ctx,
+					PipedTo:  p.pipe,
 				})
 			}
 		}
@@ -147,157 +306,291 @@ func (c *Config) calculateFileOccurrences(cmdPositions map[string][]posInfo, lin
 	return fileOccs
 }
 
-func (c *Config) processFile(path string, skipCheck bool, ignores map[string]bool, res ScanResult) {
-	path = filepath.Clean(path)
+// ignoreSet returns the set of ExtraIgnores.
+func (c *Config) ignoreSet() map[string]bool {
+	ignores := make(map[string]bool)
+	for _, cmd := range c.ExtraIgnores {
+		ignores[cmd] = true
+	}
+	return ignores
+}
 
+// getExtractor returns the Extractor for path, set up according to the config.
+func (c *Config) getExtractor(path string) Extractor {
 	ext := GetExtractor(path)
+	if nix, ok := ext.(*NixExtractor); ok {
+		nix.Inputs = c.NixInputs
+	}
+	return ext
+}
+
+// processFile returns the occurrences of commands in the file at path.
+func (c *Config) processFile(fsys fs.FS, path string, skipCheck bool, ignores map[string]bool) map[string][]Occurrence {
+	ext := c.getExtractor(path)
 	if ext == nil {
-		if !skipCheck && !isShellFile(path) {
-			return
+		if !skipCheck && !isShellFile(fsys, path) {
+			return nil
 		}
 		ext = &ShellExtractor{}
 	}
 
-	f, err := os.Open(path)
+	content, err := fs.ReadFile(fsys, path)
 	if err != nil {
-		return
+		return nil
 	}
-	defer func() { _ = f.Close() }()
 
-	content, err := io.ReadAll(f)
-	if err != nil {
-		return
+	return c.processContent(content, ext, ignores)
+}
+
+// processContent runs ext on the content of a file and returns the occurrences it finds.
+func (c *Config) processContent(content []byte, ext Extractor, ignores map[string]bool) map[string][]Occurrence {
+	var key string
+	if c.cache != nil {
+		key = c.cache.key(ext, content)
+		if occs, ok := c.cache.get(key); ok {
+			return occs
+		}
 	}
 
-	cmdPositions, err := ext.Extract(content)
-	if err != nil || len(cmdPositions) == 0 {
-		return
+	var occs map[string][]Occurrence
+	if cmdPositions, err := ext.Extract(content); err == nil && len(cmdPositions) > 0 {
+		lines := strings.Split(string(content), "\n")
+		occs = c.calculateFileOccurrences(cmdPositions, lines, ignores)
 	}
 
-	lines := strings.Split(string(content), "\n")
+	if c.cache != nil {
+		c.cache.put(key, occs)
+	}
+	return occs
+}
 
-	fileOccs := c.calculateFileOccurrences(cmdPositions, lines, ignores)
-	if len(fileOccs) > 0 {
-		res[path] = fileOccs
+// jobs returns the number of files to process concurrently.
+func (c *Config) jobs() int {
+	if c.Jobs > 0 {
+		return c.Jobs
 	}
+	return runtime.GOMAXPROCS(0)
 }
 
-// Scan recursively scans the target path (file or directory) and returns the aggregated results.
-func (c *Config) Scan(target string) (ScanResult, error) {
-	info, err := os.Stat(target)
+// stdinName is the name stdin content is reported as when no
+// StdinFilename is set.
+const stdinName = "-"
+
+// Merge adds the files of other that r does not hold yet.
+func (r ScanResult) Merge(other ScanResult) {
+	for path, cmds := range other {
+		if _, ok := r[path]; !ok {
+			r[path] = cmds
+		}
+	}
+}
+
+// ScanTargets scans several files, directories or archives into one result.
+// A target "-" is read from stdin as StdinFilename. Targets given twice are
+// scanned once.
+func (c *Config) ScanTargets(targets []string, stdin io.Reader) (ScanResult, error) {
+	res := make(ScanResult)
+	seen := make(map[string]bool)
+	isDirectory := false
+
+	for _, target := range targets {
+		if target != "-" {
+			target = filepath.Clean(target)
+		}
+		if seen[target] {
+			continue
+		}
+		seen[target] = true
+
+		var (
+			r   ScanResult
+			err error
+		)
+		if target == "-" {
+			r, err = c.ScanReader(stdin, c.StdinFilename)
+		} else {
+			r, err = c.Scan(target)
+		}
+		if err != nil {
+			return nil, err
+		}
+		isDirectory = isDirectory || c.IsDirectory
+		res.Merge(r)
+	}
+
+	c.IsDirectory = isDirectory || len(seen) > 1
+	return res, nil
+}
+
+// ScanReader scans the content of r as if it were the file name, which
+// selects the extractor; content without one is read as a shell script.
+func (c *Config) ScanReader(r io.Reader, name string) (ScanResult, error) {
+	content, err := io.ReadAll(r)
 	if err != nil {
 		return nil, err
 	}
 
-	c.IsDirectory = info.IsDir()
+	c.IsDirectory = false
+	if name == "" {
+		name = stdinName
+	}
+	if err := c.openCache(); err != nil {
+		return nil, err
+	}
 
-	ignores := make(map[string]bool)
-	for _, cmd := range c.ExtraIgnores {
-		ignores[cmd] = true
+	ignores := c.ignoreSet()
+
+	ext := c.getExtractor(name)
+	if ext == nil {
+		ext = &ShellExtractor{}
 	}
 
 	res := make(ScanResult)
+	if occs := c.processContent(content, ext, ignores); len(occs) > 0 {
+		res[name] = occs
+	}
+	return res, nil
+}
 
-	// Setup exclude matcher
-	var matcher *ignore.GitIgnore
+// Scan recursively scans the target path (file or directory) and returns the aggregated results.
+func (c *Config) Scan(target string) (ScanResult, error) {
+	if c.Rev != "" {
+		return c.scanRev(target)
+	}
+	return c.ScanFS(osFS{}, target)
+}
 
-	// Load .depextifyignore if exists in the root of target or current directory
-	ignoreFile := ".depextifyignore"
-	if c.IsDirectory {
-		ignoreFile = filepath.Join(target, ".depextifyignore")
+// ScanFS is like Scan, but reads files from fsys. root is a path in fsys, "."
+// to scan all of it, and results are keyed by paths in fsys.
+func (c *Config) ScanFS(fsys fs.FS, root string) (ScanResult, error) {
+	root = cleanPath(fsys, root)
+	info, err := fs.Stat(fsys, root)
+	if err != nil {
+		return nil, err
 	}
 
-	// If explicit excludes are provided in config, start with them
-	// We compile them as if they were lines in a gitignore file
-	ignoreLines := c.Excludes
+	// An archive holds many files, so it is reported like a directory
+	archive := !info.IsDir() && isArchive(root)
+	c.IsDirectory = info.IsDir() || archive
 
-	if content, err := os.ReadFile(ignoreFile); err == nil {
-		lines := strings.Split(string(content), "\n")
-		ignoreLines = append(ignoreLines, lines...)
-	} else if !c.IsDirectory {
-		// Try looking in current directory if target is a file
-		if content, err := os.ReadFile(".depextifyignore"); err == nil {
-			lines := strings.Split(string(content), "\n")
-			ignoreLines = append(ignoreLines, lines...)
-		}
+	ignores := c.ignoreSet()
+	if err := c.openCache(); err != nil {
+		return nil, err
 	}
 
-	if len(ignoreLines) > 0 {
-		matcher = ignore.CompileIgnoreLines(ignoreLines...)
+	res := make(ScanResult)
+
+	// Load the ignore files of the directories down to the target
+	dir := root
+	if !info.IsDir() {
+		dir = dirOf(fsys, root)
+	}
+	matcher := c.newIgnoreMatcher(fsys, dir)
+
+	only, err := c.gitSelection(fsys, root, info.IsDir())
+	if err != nil {
+		return nil, err
 	}
 
 	if !info.IsDir() {
 		// Check if the file itself is excluded
-		if matcher != nil && matcher.MatchesPath(target) {
+		if matcher.excludedTarget(root, false) || (only != nil && !only.files[root]) {
 			return res, nil
 		}
-		c.processFile(target, true, ignores, res)
+		if archive {
+			err = c.scanArchive(fsys, root, ignores, res, matcher)
+			return res, err
+		}
+		if occs := c.processFile(fsys, root, true, ignores); len(occs) > 0 {
+			res[root] = occs
+		}
+		return res, nil
+	}
+
+	if matcher.excludedTarget(root, true) {
 		return res, nil
 	}
 
+	// Walk the tree while a pool of workers processes the files found
+	paths := make(chan string)
+	var (
+		wg sync.WaitGroup
+		mu sync.Mutex
+	)
+	for range c.jobs() {
+		wg.Go(func() {
+			for path := range paths {
+				occs := c.processFile(fsys, path, false, ignores)
+				if len(occs) == 0 {
+					continue
+				}
+				mu.Lock()
+				res[path] = occs
+				mu.Unlock()
+			}
+		})
+	}
+
 	visited := make(map[string]bool)
-	err = c.walkRecursive(target, ignores, res, visited, matcher)
+	err = c.walkRecursive(fsys, root, paths, visited, matcher, only)
+	close(paths)
+	wg.Wait()
 	return res, err
 }
 
-func (c *Config) walkRecursive(path string, ignores map[string]bool, res ScanResult, visited map[string]bool, matcher *ignore.GitIgnore) error {
-	path = filepath.Clean(path)
+// walkRecursive sends the files under path to paths.
+func (c *Config) walkRecursive(fsys fs.FS, path string, paths chan<- string, visited map[string]bool, matcher *ignoreMatcher, only *fileSet) error {
+	path = cleanPath(fsys, path)
 	if visited[path] {
 		return nil
 	}
 	visited[path] = true
 
-	// Check exclusion (directory)
-	if matcher != nil && matcher.MatchesPath(path) {
-		return nil
-	}
-
-	entries, err := os.ReadDir(path)
+	entries, err := fs.ReadDir(fsys, path)
 	if err != nil {
 		return err
 	}
 
 	for _, d := range entries {
 		name := d.Name()
-		if name == "." || name == ".." || (!c.ShowHidden && name[0] == '.') {
+		if name == "." || name == ".." || (!c.ShowHidden && name[0] == '.' && !hookEntry(name)) {
 			continue
 		}
 
-		fullPath := filepath.Join(path, name)
-
-		// Check exclusion (file/subdir)
-		if matcher != nil && matcher.MatchesPath(fullPath) {
+		// git never looks into its own directory
+		if name == ".git" && !c.NoGitignore {
 			continue
 		}
 
+		fullPath := joinPath(fsys, path, name)
+
 		info, err := d.Info()
 		if err != nil {
 			continue
 		}
 
-		if info.Mode()&os.ModeSymlink != 0 {
-			resolved, err := filepath.EvalSymlinks(fullPath)
-			if err != nil {
-				continue
-			}
-			info, err = os.Stat(resolved)
+		if info.Mode()&fs.ModeSymlink != 0 {
+			// Stat follows the link; broken links are skipped
+			info, err = fs.Stat(fsys, fullPath)
 			if err != nil {
 				continue
 			}
-			if info.IsDir() {
-				if err := c.walkRecursive(fullPath, ignores, res, visited, matcher); err != nil {
-					return err
-				}
-				continue
-			}
+		}
+
+		// Check exclusion (file/subdir)
+		if matcher.excluded(fullPath, info.IsDir()) {
+			continue
 		}
 
 		if info.IsDir() {
-			if err := c.walkRecursive(fullPath, ignores, res, visited, matcher); err != nil {
+			if only != nil && !only.dirs[fullPath] {
+				continue
+			}
+			if err := c.walkRecursive(fsys, fullPath, paths, visited, matcher.enter(fullPath), only); err != nil {
 				return err
 			}
-		} else {
-			c.processFile(fullPath, false, ignores, res)
+		} else if only == nil || only.files[fullPath] {
+			paths <- fullPath
 		}
 	}
 	return nil
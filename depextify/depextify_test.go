@@ -1,9 +1,13 @@
 package depextify
 
 import (
+	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"testing/fstest"
 
 	"github.com/stretchr/testify/require"
 )
@@ -194,12 +198,12 @@ func TestIsShellFile(t *testing.T) {
 			err := os.WriteFile(path, []byte(tt.content), 0755)
 			require.NoError(t, err)
 
-			require.Equal(t, tt.expected, isShellFile(path))
+			require.Equal(t, tt.expected, isShellFile(osFS{}, path))
 		})
 	}
 
 	t.Run("non-existent file", func(t *testing.T) {
-		require.False(t, isShellFile(filepath.Join(tmpDir, "doesnotexist")))
+		require.False(t, isShellFile(osFS{}, filepath.Join(tmpDir, "doesnotexist")))
 	})
 }
 
@@ -443,3 +447,138 @@ func TestResult_Format_InvalidStyleAndLexer(t *testing.T) {
 }
 
 
+func TestResult_Format_Context(t *testing.T) {
+	res := ScanResult{
+		"a.ipynb": {
+			"pip": {{Line: 10, Col: 2, Len: 3, FullLine: "!pip install pandas", Context: "cell 1:2"}},
+		},
+	}
+	cfg := &Config{ShowPos: true, LexerName: DefaultLexer, StyleName: DefaultStyle}
+	require.Equal(t, "pip:\n  10:  [cell 1:2]  !pip install pandas\n", res.Format(cfg))
+
+	jsonStr, err := res.JSON(cfg)
+	require.NoError(t, err)
+	require.Contains(t, jsonStr, `"Context": "cell 1:2"`)
+}
+
+func TestScanFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"project/build.sh":           {Data: []byte("curl example.com\n")},
+		"project/Makefile":           {Data: []byte("all:\n\tgo build\n")},
+		"project/tools/gen":          {Data: []byte("#!/bin/sh\nprotoc --go_out=. api.proto\n")},
+		"project/notes.txt":          {Data: []byte("rsync -a . host:\n")},
+		"project/.hidden/x.sh":       {Data: []byte("shellcheck x.sh\n")},
+		"project/vendor/lib.sh":      {Data: []byte("wget example.com\n")},
+		"project/.depextifyignore":   {Data: []byte("vendor/\n")},
+		"project/link.sh":            {Data: []byte("build.sh"), Mode: fs.ModeSymlink},
+		"project/broken.sh":          {Data: []byte("missing.sh"), Mode: fs.ModeSymlink},
+		"project/release/bundle.tar": {Data: buildTar(t, []tarEntry{{name: "install.sh", content: "jq . x\n"}}, false)},
+	}
+
+	t.Run("directory", func(t *testing.T) {
+		config := &Config{}
+		res, err := config.ScanFS(fsys, "project")
+		require.NoError(t, err)
+		require.True(t, config.IsDirectory)
+
+		require.Equal(t, []Occurrence{{Line: 1, Col: 1, Len: 4, FullLine: "curl example.com"}}, res["project/build.sh"]["curl"])
+		require.Contains(t, res["project/Makefile"], "go")
+		require.Contains(t, res["project/tools/gen"], "protoc")
+		require.Contains(t, res["project/link.sh"], "curl")
+		require.NotContains(t, res, "project/notes.txt")
+		require.NotContains(t, res, "project/.hidden/x.sh")
+		require.NotContains(t, res, "project/vendor/lib.sh")
+		require.NotContains(t, res, "project/broken.sh")
+		// Archives are only looked into when they are the target
+		require.NotContains(t, res, "project/release/bundle.tar!/install.sh")
+	})
+
+	t.Run("whole filesystem", func(t *testing.T) {
+		res, err := (&Config{ShowHidden: true}).ScanFS(fsys, ".")
+		require.NoError(t, err)
+		require.Contains(t, res, "project/build.sh")
+		require.Contains(t, res, "project/.hidden/x.sh")
+	})
+
+	t.Run("archive", func(t *testing.T) {
+		res, err := (&Config{}).ScanFS(fsys, "project/release/bundle.tar")
+		require.NoError(t, err)
+		require.Contains(t, res["project/release/bundle.tar!/install.sh"], "jq")
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		_, err := (&Config{}).ScanFS(fsys, "nope")
+		require.ErrorIs(t, err, fs.ErrNotExist)
+	})
+}
+
+func TestScanTargets(t *testing.T) {
+	tmpDir := t.TempDir()
+	scripts := filepath.Join(tmpDir, "scripts")
+	require.NoError(t, os.Mkdir(scripts, 0755))
+	deploy := filepath.Join(scripts, "deploy.sh")
+	require.NoError(t, os.WriteFile(deploy, []byte("rsync -a dist/ host:\n"), 0600))
+	makefile := filepath.Join(tmpDir, "Makefile")
+	require.NoError(t, os.WriteFile(makefile, []byte("all:\n\tgo build\n"), 0600))
+
+	t.Run("merged and de-duplicated", func(t *testing.T) {
+		config := &Config{}
+		res, err := config.ScanTargets([]string{scripts, makefile, deploy, scripts + "/"}, nil)
+		require.NoError(t, err)
+		require.True(t, config.IsDirectory)
+		require.Len(t, res, 2)
+		require.Contains(t, res[deploy], "rsync")
+		require.Contains(t, res[makefile], "go")
+	})
+
+	t.Run("single file", func(t *testing.T) {
+		config := &Config{}
+		_, err := config.ScanTargets([]string{makefile, makefile}, nil)
+		require.NoError(t, err)
+		require.False(t, config.IsDirectory)
+	})
+
+	t.Run("stdin", func(t *testing.T) {
+		config := &Config{StdinFilename: "Dockerfile"}
+		res, err := config.ScanTargets([]string{"-", makefile}, strings.NewReader("FROM alpine\nRUN apk add jq\n"))
+		require.NoError(t, err)
+		require.Equal(t, []Occurrence{{Line: 2, Col: 5, Len: 3, FullLine: "RUN apk add jq"}}, res["Dockerfile"]["apk"])
+		require.Contains(t, res[makefile], "go")
+	})
+
+	t.Run("stdin without a name", func(t *testing.T) {
+		config := &Config{}
+		res, err := config.ScanReader(strings.NewReader("curl example.com\n"), "")
+		require.NoError(t, err)
+		require.False(t, config.IsDirectory)
+		require.Contains(t, res["-"], "curl")
+	})
+
+	t.Run("missing target", func(t *testing.T) {
+		_, err := (&Config{}).ScanTargets([]string{makefile, filepath.Join(tmpDir, "nope")}, nil)
+		require.Error(t, err)
+	})
+}
+
+func TestScan_Jobs(t *testing.T) {
+	tmpDir := t.TempDir()
+	for i := range 50 {
+		dir := filepath.Join(tmpDir, fmt.Sprintf("pkg%02d", i%7))
+		require.NoError(t, os.MkdirAll(dir, 0755))
+		script := fmt.Sprintf("curl example.com/%d\njq .\ntool%d --version\n", i, i)
+		require.NoError(t, os.WriteFile(filepath.Join(dir, fmt.Sprintf("s%02d.sh", i)), []byte(script), 0600))
+	}
+
+	sequential := &Config{Jobs: 1, ShowPos: true}
+	want, err := sequential.Scan(tmpDir)
+	require.NoError(t, err)
+	require.Len(t, want, 50)
+
+	for _, jobs := range []int{0, 4, 64} {
+		config := &Config{Jobs: jobs, ShowPos: true}
+		got, err := config.Scan(tmpDir)
+		require.NoError(t, err)
+		require.Equal(t, want, got)
+		require.Equal(t, want.Format(sequential), got.Format(config))
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"bytes"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
 
 	"gopkg.in/yaml.v3"
@@ -16,6 +17,13 @@ type (
 		line uint
 		col  uint
 		len  uint
+		// text overrides the file line shown for the occurrence when the
+		// extractor works on a view of the file (e.g. a notebook cell).
+		text string
+		// ctx locates the occurrence within the file beyond its line number.
+		ctx string
+		// pipe is the command the output is piped into.
+		pipe string
 	}
 
 	// Extractor interface defines the contract for command extractors.
@@ -28,19 +36,39 @@ type (
 	// MakefileExtractor extracts commands from Makefiles.
 	MakefileExtractor struct{}
 
-	// DockerfileExtractor extracts commands from Dockerfiles.
-	DockerfileExtractor struct{}
+	// DockerfileExtractor extracts commands from RUN instructions of
+	// Dockerfiles, Containerfiles and Earthfiles.
+	DockerfileExtractor struct {
+		// Earthfile attributes commands to Earthly targets instead of build stages.
+		Earthfile bool
+	}
 
 	// YAMLExtractor extracts commands from YAML files.
 	YAMLExtractor struct{}
+
+	// multiExtractor runs several extractors over the same file and merges their results.
+	multiExtractor []Extractor
 )
 
 var (
 	reTaskfile   = regexp.MustCompile(`(Taskfile|taskfile)\.(ya?ml|yml)`)
 	reMakefile   = regexp.MustCompile("([Mm]akefile|MAKEFILE|GNUmakefile)")
-	reDockerfile = regexp.MustCompile(`(Dockerfile|DOCKERFILE)(.*)?`)
+	reDockerfile = regexp.MustCompile(`^(Dockerfile|DOCKERFILE|Containerfile|CONTAINERFILE)([.-][\w.-]+)?$|\.(docker|container)file$`)
+	reEarthfile  = regexp.MustCompile(`^Earthfile$`)
+	reMarkdown   = regexp.MustCompile(`\.(md|markdown)$`)
+	reNotebook   = regexp.MustCompile(`\.ipynb$`)
+	reGoSource   = regexp.MustCompile(`\.go$`)
+	rePython     = regexp.MustCompile(`\.py$`)
+	reNode       = regexp.MustCompile(`\.[cm]?[jt]sx?$`)
+	reRuby       = regexp.MustCompile(`(\.rb|^Rakefile)$`)
+	reRust       = regexp.MustCompile(`\.rs$`)
+	reNix        = regexp.MustCompile(`\.nix$`)
 )
 
+// reNotDockerfile matches documents and backups named after a Dockerfile,
+// e.g. Dockerfile.md.
+var reNotDockerfile = regexp.MustCompile(`\.(md|markdown|rst|adoc|txt|bak|orig|rej|old|swp|tmp|dockerignore)$`)
+
 // analyzeShellCode parses the given shell code and returns command occurrences.
 // Positions are relative to the start of the code string.
 func analyzeShellCode(code string) (map[string][]posInfo, error) {
@@ -100,6 +128,33 @@ func (e *MakefileExtractor) Extract(content []byte) (map[string][]posInfo, error
 	return results, nil
 }
 
+var (
+	reDockerStage     = regexp.MustCompile(`(?i)^\s*FROM\s.*\sAS\s+(\S+)\s*$`)
+	reEarthfileTarget = regexp.MustCompile(`^([A-Za-z][\w.-]*):\s*$`)
+	reRunFlag         = regexp.MustCompile(`^(?:\s|\\\n)*--[\w-]+(?:=\S*)?`)
+)
+
+// blankRunFlags replaces the --mount=..., --network=..., --push, ... flags
+// at the start of a RUN script with spaces to keep positions.
+func blankRunFlags(script string) string {
+	offset := 0
+	for {
+		loc := reRunFlag.FindStringIndex(script[offset:])
+		if loc == nil {
+			return script
+		}
+		end := offset + loc[1]
+		blank := strings.Map(func(r rune) rune {
+			if r == '\n' {
+				return r
+			}
+			return ' '
+		}, script[offset:end])
+		script = script[:offset] + blank + script[end:]
+		offset = end
+	}
+}
+
 func (e *DockerfileExtractor) Extract(content []byte) (map[string][]posInfo, error) {
 	results := make(map[string][]posInfo)
 	scanner := bufio.NewScanner(bytes.NewReader(content))
@@ -108,6 +163,23 @@ func (e *DockerfileExtractor) Extract(content []byte) (map[string][]posInfo, err
 	var buffer strings.Builder
 	startLine := 0
 	inRun := false
+	// Build stage or Earthly target the current instruction belongs to
+	ctx := ""
+
+	flush := func() {
+		cmds, err := analyzeShellCode(blankRunFlags(buffer.String()))
+		if err == nil {
+			for cmd, infos := range cmds {
+				for _, info := range infos {
+					info.line += uint(startLine - 1)
+					info.ctx = ctx
+					results[cmd] = append(results[cmd], info)
+				}
+			}
+		}
+		inRun = false
+		buffer.Reset()
+	}
 
 	for scanner.Scan() {
 		lineNum++
@@ -120,21 +192,21 @@ func (e *DockerfileExtractor) Extract(content []byte) (map[string][]posInfo, err
 
 			if !strings.HasSuffix(trimmed, "\\") {
 				// End of RUN
-				script := buffer.String()
-
-				cmds, err := analyzeShellCode(script)
-				if err == nil {
-					for cmd, infos := range cmds {
-						for _, info := range infos {
-							info.line += uint(startLine - 1)
-							results[cmd] = append(results[cmd], info)
-						}
-					}
-				}
+				flush()
+			}
+			continue
+		}
 
-				inRun = false
-				buffer.Reset()
+		if e.Earthfile {
+			if m := reEarthfileTarget.FindStringSubmatch(line); m != nil {
+				ctx = "target +" + m[1]
+				continue
 			}
+		} else if m := reDockerStage.FindStringSubmatch(line); m != nil {
+			ctx = "stage " + m[1]
+			continue
+		} else if fields := strings.Fields(trimmed); len(fields) > 0 && strings.EqualFold(fields[0], "FROM") {
+			ctx = ""
 			continue
 		}
 
@@ -143,7 +215,7 @@ func (e *DockerfileExtractor) Extract(content []byte) (map[string][]posInfo, err
 			if idx >= 0 {
 				// Check if it's JSON form like RUN ["echo", ...]
 				contentStart := idx + 3
-				rest := strings.TrimSpace(line[contentStart:])
+				rest := strings.TrimSpace(blankRunFlags(line[contentStart:]))
 				if strings.HasPrefix(rest, "[") {
 					continue // Skip exec form
 				}
@@ -157,17 +229,7 @@ func (e *DockerfileExtractor) Extract(content []byte) (map[string][]posInfo, err
 
 				if !strings.HasSuffix(trimmed, "\\") {
 					// Single line RUN
-					inRun = false
-					cmds, err := analyzeShellCode(buffer.String())
-					if err == nil {
-						for cmd, infos := range cmds {
-							for _, info := range infos {
-								info.line += uint(startLine - 1)
-								results[cmd] = append(results[cmd], info)
-							}
-						}
-					}
-					buffer.Reset()
+					flush()
 				}
 			}
 		}
@@ -232,6 +294,30 @@ func (e *YAMLExtractor) Extract(content []byte) (map[string][]posInfo, error) {
 	return results, nil
 }
 
+func (e multiExtractor) Extract(content []byte) (map[string][]posInfo, error) {
+	results := make(map[string][]posInfo)
+	var firstErr error
+	failed := 0
+	for _, ext := range e {
+		cmds, err := ext.Extract(content)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			failed++
+			continue
+		}
+		for cmd, infos := range cmds {
+			results[cmd] = append(results[cmd], infos...)
+		}
+	}
+	// Only fail when no extractor could make sense of the file.
+	if failed == len(e) {
+		return nil, firstErr
+	}
+	return results, nil
+}
+
 func applyYAMLOffset(cmds map[string][]posInfo, val *yaml.Node, lines [][]byte, results map[string][]posInfo) {
 	shellLines := strings.Split(val.Value, "\n")
 	baseLine := val.Line
@@ -261,6 +347,35 @@ func applyYAMLOffset(cmds map[string][]posInfo, val *yaml.Node, lines [][]byte,
 	}
 }
 
+// lineIndex maps byte offsets in a file to line numbers.
+type lineIndex []int
+
+func newLineIndex(content []byte) lineIndex {
+	var idx lineIndex
+	for i, b := range content {
+		if b == '\n' {
+			idx = append(idx, i)
+		}
+	}
+	return idx
+}
+
+// line returns the 1-based line containing the byte just before offset, i.e.
+// the line a token ends on when offset is the decoder's input offset after it.
+func (idx lineIndex) line(offset int64) int {
+	return sort.SearchInts(idx, int(offset-1)) + 1
+}
+
+// position returns the 1-based line and column of the byte at offset.
+func (idx lineIndex) position(offset int) (uint, uint) {
+	n := sort.SearchInts(idx, offset)
+	lineStart := 0
+	if n > 0 {
+		lineStart = idx[n-1] + 1
+	}
+	return uint(n + 1), uint(offset - lineStart + 1)
+}
+
 // GetExtractor returns the appropriate Extractor for the given file path.
 // It returns nil if no specific extractor matches (caller should decide fallback, e.g. check isShellFile).
 func GetExtractor(path string) Extractor {
@@ -269,9 +384,12 @@ func GetExtractor(path string) Extractor {
 	if reMakefile.MatchString(base) {
 		return &MakefileExtractor{}
 	}
-	if reDockerfile.MatchString(base) {
+	if reDockerfile.MatchString(base) && !reNotDockerfile.MatchString(base) {
 		return &DockerfileExtractor{}
 	}
+	if reEarthfile.MatchString(base) {
+		return &DockerfileExtractor{Earthfile: true}
+	}
 	// GitHub Actions
 	if strings.Contains(path, ".github/workflows") && (strings.HasSuffix(path, ".yml") || strings.HasSuffix(path, ".yaml")) {
 		return &YAMLExtractor{}
@@ -280,6 +398,55 @@ func GetExtractor(path string) Extractor {
 	if reTaskfile.MatchString(base) {
 		return &YAMLExtractor{}
 	}
+	// Developer hooks
+	if rePreCommit.MatchString(base) {
+		return &PreCommitExtractor{}
+	}
+	if reLefthook.MatchString(base) {
+		return &LefthookExtractor{}
+	}
+	if reMarkdown.MatchString(base) {
+		return &MarkdownExtractor{}
+	}
+	if reNotebook.MatchString(base) {
+		return &NotebookExtractor{}
+	}
+	if reNix.MatchString(base) {
+		return &NixExtractor{}
+	}
+	// Host provisioning
+	if reSystemdUnit.MatchString(base) {
+		return &SystemdExtractor{}
+	}
+	if reSystemCrontab.MatchString(filepath.ToSlash(path)) {
+		return &CrontabExtractor{System: true}
+	}
+	if reCrontab.MatchString(base) {
+		return &CrontabExtractor{}
+	}
+	if reSupervisord.MatchString(filepath.ToSlash(path)) {
+		return &SupervisordExtractor{}
+	}
+	// Subprocess calls in application source
+	if reGoSource.MatchString(base) {
+		return multiExtractor{&GoExtractor{}, &GoGenerateExtractor{}}
+	}
+	if rePython.MatchString(base) {
+		return &PythonExtractor{}
+	}
+	if reNode.MatchString(base) {
+		return &NodeExtractor{}
+	}
+	if reRuby.MatchString(base) {
+		return &RubyExtractor{}
+	}
+	if reRust.MatchString(base) {
+		return &RustExtractor{}
+	}
+
+	if hook, ok := gitHookName(path); ok {
+		return &GitHookExtractor{Hook: hook}
+	}
 
 	return nil
 }
@@ -45,6 +45,52 @@ RUN ["echo", "hello"]
 	require.NotContains(t, res, "echo")
 }
 
+func TestExtractDockerfile_Stages(t *testing.T) {
+	t.Run("Dockerfile", func(t *testing.T) {
+		content := `FROM golang:1.25 AS build
+RUN --mount=type=cache,target=/root/.cache/go-build \
+    --mount=type=secret,id=netrc go build ./...
+RUN --network=none ["make", "test"]
+FROM alpine
+RUN apk add curl
+`
+		res, err := (&DockerfileExtractor{}).Extract([]byte(content))
+		require.NoError(t, err)
+
+		require.Equal(t, []posInfo{{line: 3, col: 34, len: 2, ctx: "stage build"}}, res["go"])
+		require.Equal(t, "", res["apk"][0].ctx)
+		require.NotContains(t, res, "make")
+	})
+
+	t.Run("FROM without a stage name", func(t *testing.T) {
+		content := "FROM golang:1.25 AS build\nRUN go build ./...\nfrom\talpine\nRUN apk add curl\n"
+		res, err := (&DockerfileExtractor{}).Extract([]byte(content))
+		require.NoError(t, err)
+
+		require.Equal(t, "stage build", res["go"][0].ctx)
+		require.Equal(t, []posInfo{{line: 4, col: 5, len: 3}}, res["apk"])
+	})
+
+	t.Run("Earthfile", func(t *testing.T) {
+		content := `VERSION 0.8
+FROM golang:1.25
+
+build:
+    RUN go build -o out/app
+    SAVE ARTIFACT out/app
+
+test:
+    FROM +build
+    RUN --privileged gotestsum
+`
+		res, err := (&DockerfileExtractor{Earthfile: true}).Extract([]byte(content))
+		require.NoError(t, err)
+
+		require.Equal(t, []posInfo{{line: 5, col: 9, len: 2, ctx: "target +build"}}, res["go"])
+		require.Equal(t, []posInfo{{line: 10, col: 22, len: 9, ctx: "target +test"}}, res["gotestsum"])
+	})
+}
+
 func TestExtractYAML(t *testing.T) {
 	extractor := &YAMLExtractor{}
 	t.Run("GitHub Actions", func(t *testing.T) {
@@ -119,10 +165,39 @@ func TestGetExtractor(t *testing.T) {
 		{"GNUmakefile", &MakefileExtractor{}},
 		{"Dockerfile", &DockerfileExtractor{}},
 		{"Dockerfile.dev", &DockerfileExtractor{}},
+		{"Dockerfile-dev", &DockerfileExtractor{}},
+		{"Dockerfile.md", &MarkdownExtractor{}},
+		{"notes.dockerfile.bak", nil},
+		{"Dockerfile.bak", nil},
+		{"Containerfile", &DockerfileExtractor{}},
+		{"build/app.dockerfile", &DockerfileExtractor{}},
+		{"Earthfile", &DockerfileExtractor{}},
 		{".github/workflows/ci.yml", &YAMLExtractor{}},
 		{".github/workflows/deploy.yaml", &YAMLExtractor{}},
 		{"script.sh", nil},
 		{"Taskfile.yml", &YAMLExtractor{}},
+		{"README.md", &MarkdownExtractor{}},
+		{"docs/runbook.markdown", &MarkdownExtractor{}},
+		{"analysis.ipynb", &NotebookExtractor{}},
+		{"main.go", multiExtractor{&GoExtractor{}, &GoGenerateExtractor{}}},
+		{"tools/build.py", &PythonExtractor{}},
+		{"index.mjs", &NodeExtractor{}},
+		{"app.tsx", &NodeExtractor{}},
+		{"Rakefile", &RubyExtractor{}},
+		{"lib/deploy.rb", &RubyExtractor{}},
+		{"src/main.rs", &RustExtractor{}},
+		{"deploy/app.service", &SystemdExtractor{}},
+		{"deploy/app.timer", &SystemdExtractor{}},
+		{"crontab", &CrontabExtractor{}},
+		{"etc/cron.d/backup", &CrontabExtractor{}},
+		{"flake.nix", &NixExtractor{}},
+		{".pre-commit-config.yaml", &PreCommitExtractor{}},
+		{".pre-commit-hooks.yaml", &PreCommitExtractor{}},
+		{"lefthook.yml", &LefthookExtractor{}},
+		{".lefthook-local.yaml", &LefthookExtractor{}},
+		{"supervisord.conf", &SupervisordExtractor{}},
+		{"etc/supervisor/conf.d/app.conf", &SupervisordExtractor{}},
+		{"etc/nginx/conf.d/app.conf", nil},
 	}
 
 	for _, tt := range tests {
@@ -133,4 +208,7 @@ func TestGetExtractor(t *testing.T) {
 			require.IsType(t, tt.expected, got)
 		}
 	}
+
+	// The extractors of Go files, beyond their type
+	require.Equal(t, multiExtractor{&GoExtractor{}, &GoGenerateExtractor{}}, GetExtractor("main.go"))
 }
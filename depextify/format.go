@@ -3,12 +3,14 @@ package depextify
 import (
 	"encoding/json"
 	"fmt"
+	"html/template"
 	"maps"
 	"slices"
 	"strings"
 
 	"github.com/alecthomas/chroma/v2"
 	"github.com/alecthomas/chroma/v2/formatters"
+	"github.com/alecthomas/chroma/v2/formatters/html"
 	"github.com/alecthomas/chroma/v2/lexers"
 	"github.com/alecthomas/chroma/v2/styles"
 	"gopkg.in/yaml.v3"
@@ -31,9 +33,25 @@ type highlightRange struct {
 	end   int
 }
 
-var formatter = formatters.TTY256
+// codeFormatter renders code highlighted by chroma for an output format.
+type codeFormatter struct {
+	chroma.Formatter
+	// mark renders the part of the code to point out, the command.
+	mark func(s string) string
+}
+
+var (
+	ttyFormatter = codeFormatter{
+		Formatter: formatters.TTY256,
+		mark:      func(s string) string { return colorBold + colorRed + s + colorReset },
+	}
+	htmlFormatter = codeFormatter{
+		Formatter: html.New(html.PreventSurroundingPre(true)),
+		mark:      func(s string) string { return "<mark>" + template.HTMLEscapeString(s) + "</mark>" },
+	}
+)
 
-func highlightCode(code string, lexerName, styleName string, hl *highlightRange) string {
+func highlightCode(code string, lexerName, styleName string, hl *highlightRange, formatter codeFormatter) string {
 	lexer := lexers.Get(lexerName)
 	if lexer == nil {
 		lexer = lexers.Get(DefaultLexer)
@@ -43,13 +61,15 @@ func highlightCode(code string, lexerName, styleName string, hl *highlightRange)
 		style = styles.Get(DefaultStyle)
 	}
 
+	var sb strings.Builder
+
 	iterator, err := lexer.Tokenise(nil, code)
 	if err != nil {
-		return code // Fallback
+		// Fallback to plain text, which the formatter still escapes
+		_ = formatter.Format(&sb, style, chroma.Literator(chroma.Token{Type: chroma.Text, Value: code}))
+		return strings.TrimSpace(sb.String())
 	}
 
-	var sb strings.Builder
-
 	// Helper to format a single token using chroma formatter
 	formatToken := func(t chroma.Token) {
 		_ = formatter.Format(&sb, style, chroma.Literator(t))
@@ -57,7 +77,7 @@ func highlightCode(code string, lexerName, styleName string, hl *highlightRange)
 
 	// Helper to format a highlighted string
 	formatHighlight := func(s string) {
-		sb.WriteString(colorBold + colorRed + s + colorReset)
+		sb.WriteString(formatter.mark(s))
 	}
 
 	currentPos := 0
@@ -81,7 +101,7 @@ func highlightCode(code string, lexerName, styleName string, hl *highlightRange)
 			}
 
 			// Highlighted part
-			// We strip syntax highlighting for the command itself and enforce Bold Red.
+			// We strip syntax highlighting for the command itself and mark it instead.
 
 			mid := token.Value[ovStart-currentPos : ovEnd-currentPos]
 			formatHighlight(mid)
@@ -113,8 +133,23 @@ func min(a, b int) int {
 	return b
 }
 
+// lexerName returns the lexer highlighting the lines of the file at path:
+// LexerName, or the lexer matching the file if it is the default one.
+func (c *Config) lexerName(path string) string {
+	if c.LexerName == DefaultLexer {
+		if l := lexers.Match(path); l != nil {
+			return l.Config().Name
+		}
+	}
+	return c.LexerName
+}
+
 // Format returns a formatted string representation of the result.
 func (r ScanResult) Format(c *Config) string {
+	if c.GroupBy == GroupByCommand {
+		return r.formatByCommand(c)
+	}
+
 	var sb strings.Builder
 
 	globalLineWidth := 0
@@ -191,22 +226,22 @@ func (r ScanResult) Format(c *Config) string {
 						start := occ.Col - 1
 						end := start + occ.Len
 
-						lexerName := c.LexerName
-						if lexerName == DefaultLexer {
-							if l := lexers.Match(path); l != nil {
-								lexerName = l.Config().Name
-							}
-						}
-
 						hl := &highlightRange{start: start, end: end}
 						if start < 0 || end > len(occ.FullLine) {
 							hl = nil
 						}
 
-						content = highlightCode(content, lexerName, c.StyleName, hl)
+						content = highlightCode(content, c.lexerName(path), c.StyleName, hl, ttyFormatter)
 					} else {
 						content = strings.TrimSpace(content)
 					}
+					if occ.Context != "" {
+						ctx := "[" + occ.Context + "]"
+						if c.UseColor {
+							ctx = colorCyan + ctx + colorReset
+						}
+						content = ctx + "  " + content
+					}
 					fmt.Fprintf(&sb, "%s  %s%s  %s\n", indent, ln, cln, content)
 				}
 			}
@@ -217,6 +252,10 @@ func (r ScanResult) Format(c *Config) string {
 
 // JSON returns the JSON encoding of the result.
 func (r ScanResult) JSON(c *Config) (string, error) {
+	if c.GroupBy == GroupByCommand {
+		return r.jsonByCommand(c)
+	}
+
 	var data interface{} = r
 
 	if !c.ShowPos {
@@ -252,6 +291,10 @@ func (r ScanResult) JSON(c *Config) (string, error) {
 
 // YAML returns the YAML encoding of the result.
 func (r ScanResult) YAML(c *Config) (string, error) {
+	if c.GroupBy == GroupByCommand {
+		return r.yamlByCommand(c)
+	}
+
 	var data interface{} = r
 
 	if !c.ShowPos {
@@ -0,0 +1,260 @@
+package depextify
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// Package ecosystems manifests can be generated for.
+const (
+	EcosystemApt  = "apt"
+	EcosystemApk  = "apk"
+	EcosystemDnf  = "dnf"
+	EcosystemBrew = "brew"
+	EcosystemNix  = "nix"
+)
+
+var ecosystems = []string{EcosystemApt, EcosystemApk, EcosystemDnf, EcosystemBrew, EcosystemNix}
+
+// packageRows are the packages providing commands, in the order of
+// ecosystems: Debian/Ubuntu, Alpine, Fedora, Homebrew and nixpkgs. An empty
+// name means the package is not known for that ecosystem.
+var packageRows = [][6]string{
+	// command, apt, apk, dnf, brew, nix
+	{"ansible", "ansible", "ansible", "ansible", "ansible", "ansible"},
+	{"age", "age", "age", "age", "age", "age"},
+	{"aria2c", "aria2", "aria2", "aria2", "aria2", "aria2"},
+	{"autoconf", "autoconf", "autoconf", "autoconf", "autoconf", "autoconf"},
+	{"automake", "automake", "automake", "automake", "automake", "automake"},
+	{"aws", "awscli", "aws-cli", "awscli2", "awscli", "awscli2"},
+	{"bat", "", "bat", "bat", "bat", "bat"},
+	{"bc", "bc", "bc", "bc", "bc", "bc"},
+	{"bundle", "ruby-bundler", "ruby-bundler", "rubygem-bundler", "ruby", "bundler"},
+	{"bunzip2", "bzip2", "bzip2", "bzip2", "bzip2", "bzip2"},
+	{"bzip2", "bzip2", "bzip2", "bzip2", "bzip2", "bzip2"},
+	{"cargo", "cargo", "cargo", "cargo", "rust", "cargo"},
+	{"clang", "clang", "clang", "clang", "llvm", "clang"},
+	{"cmake", "cmake", "cmake", "cmake", "cmake", "cmake"},
+	{"composer", "composer", "composer", "composer", "composer", "phpPackages.composer"},
+	{"curl", "curl", "curl", "curl", "curl", "curl"},
+	{"dig", "dnsutils", "bind-tools", "bind-utils", "bind", "dnsutils"},
+	{"direnv", "direnv", "direnv", "direnv", "direnv", "direnv"},
+	{"docker", "docker.io", "docker-cli", "moby-engine", "docker", "docker"},
+	{"dot", "graphviz", "graphviz", "graphviz", "graphviz", "graphviz"},
+	{"doxygen", "doxygen", "doxygen", "doxygen", "doxygen", "doxygen"},
+	{"entr", "entr", "entr", "entr", "entr", "entr"},
+	{"envsubst", "gettext-base", "gettext", "gettext", "gettext", "gettext"},
+	{"fd", "", "fd", "fd-find", "fd", "fd"},
+	{"ffmpeg", "ffmpeg", "ffmpeg", "ffmpeg-free", "ffmpeg", "ffmpeg"},
+	{"file", "file", "file", "file", "file", "file"},
+	{"fzf", "fzf", "fzf", "fzf", "fzf", "fzf"},
+	{"g++", "g++", "g++", "gcc-c++", "gcc", "gcc"},
+	{"gawk", "gawk", "gawk", "gawk", "gawk", "gawk"},
+	{"gcc", "gcc", "gcc", "gcc", "gcc", "gcc"},
+	{"gdb", "gdb", "gdb", "gdb", "gdb", "gdb"},
+	{"gem", "ruby", "ruby", "rubygems", "ruby", "ruby"},
+	{"gh", "gh", "github-cli", "gh", "gh", "gh"},
+	{"git", "git", "git", "git", "git", "git"},
+	{"git-lfs", "git-lfs", "git-lfs", "git-lfs", "git-lfs", "git-lfs"},
+	{"go", "golang-go", "go", "golang", "go", "go"},
+	{"golangci-lint", "", "golangci-lint", "", "golangci-lint", "golangci-lint"},
+	{"goreleaser", "", "", "", "goreleaser", "goreleaser"},
+	{"gpg", "gnupg", "gnupg", "gnupg2", "gnupg", "gnupg"},
+	{"gradle", "gradle", "gradle", "", "gradle", "gradle"},
+	{"gunzip", "gzip", "gzip", "gzip", "gzip", "gzip"},
+	{"gzip", "gzip", "gzip", "gzip", "gzip", "gzip"},
+	{"hadolint", "", "", "", "hadolint", "hadolint"},
+	{"helm", "", "helm", "helm", "helm", "kubernetes-helm"},
+	{"hg", "mercurial", "mercurial", "mercurial", "mercurial", "mercurial"},
+	{"htop", "htop", "htop", "htop", "htop", "htop"},
+	{"hugo", "hugo", "hugo", "hugo", "hugo", "hugo"},
+	{"inotifywait", "inotify-tools", "inotify-tools", "inotify-tools", "", "inotify-tools"},
+	{"ip", "iproute2", "iproute2", "iproute", "", "iproute2"},
+	{"java", "default-jre", "openjdk21-jre", "java-21-openjdk", "openjdk", "jdk"},
+	{"javac", "default-jdk", "openjdk21-jdk", "java-21-openjdk-devel", "openjdk", "jdk"},
+	{"jq", "jq", "jq", "jq", "jq", "jq"},
+	{"just", "just", "just", "just", "just", "just"},
+	{"kubectl", "", "kubectl", "kubernetes-client", "kubernetes-cli", "kubectl"},
+	{"less", "less", "less", "less", "less", "less"},
+	{"lsof", "lsof", "lsof", "lsof", "lsof", "lsof"},
+	{"make", "make", "make", "make", "make", "gnumake"},
+	{"meson", "meson", "meson", "meson", "meson", "meson"},
+	{"mvn", "maven", "maven", "maven", "maven", "maven"},
+	{"nc", "netcat-openbsd", "netcat-openbsd", "nmap-ncat", "netcat", "netcat"},
+	{"ninja", "ninja-build", "", "ninja-build", "ninja", "ninja"},
+	{"nmap", "nmap", "nmap", "nmap", "nmap", "nmap"},
+	{"node", "nodejs", "nodejs", "nodejs", "node", "nodejs"},
+	{"notify-send", "libnotify-bin", "libnotify", "libnotify", "", "libnotify"},
+	{"npm", "npm", "npm", "nodejs-npm", "node", "nodejs"},
+	{"npx", "npm", "npm", "nodejs-npm", "node", "nodejs"},
+	{"nvim", "neovim", "neovim", "neovim", "neovim", "neovim"},
+	{"openssl", "openssl", "openssl", "openssl", "openssl", "openssl"},
+	{"pandoc", "pandoc", "pandoc", "pandoc", "pandoc", "pandoc"},
+	{"parallel", "parallel", "parallel", "parallel", "parallel", "parallel"},
+	{"patch", "patch", "patch", "patch", "gpatch", "gnupatch"},
+	{"perl", "perl", "perl", "perl", "perl", "perl"},
+	{"php", "php-cli", "php", "php-cli", "php", "php"},
+	{"ping", "iputils-ping", "iputils", "iputils", "", "iputils"},
+	{"pip", "python3-pip", "py3-pip", "python3-pip", "python", "python3Packages.pip"},
+	{"pip3", "python3-pip", "py3-pip", "python3-pip", "python", "python3Packages.pip"},
+	{"pkg-config", "pkg-config", "pkgconf", "pkgconf-pkg-config", "pkgconf", "pkg-config"},
+	{"pre-commit", "pre-commit", "pre-commit", "pre-commit", "pre-commit", "pre-commit"},
+	{"protoc", "protobuf-compiler", "protobuf", "protobuf-compiler", "protobuf", "protobuf"},
+	{"ps", "procps", "procps-ng", "procps-ng", "", "procps"},
+	{"psql", "postgresql-client", "postgresql-client", "postgresql", "libpq", "postgresql"},
+	{"pv", "pv", "pv", "pv", "pv", "pv"},
+	{"python", "python-is-python3", "python3", "python-unversioned-command", "python", "python3"},
+	{"python3", "python3", "python3", "python3", "python", "python3"},
+	{"redis-cli", "redis-tools", "redis", "redis", "redis", "redis"},
+	{"rg", "ripgrep", "ripgrep", "ripgrep", "ripgrep", "ripgrep"},
+	{"rsync", "rsync", "rsync", "rsync", "rsync", "rsync"},
+	{"ruby", "ruby", "ruby", "ruby", "ruby", "ruby"},
+	{"rustc", "rustc", "rust", "rust", "rust", "rustc"},
+	{"scp", "openssh-client", "openssh-client", "openssh-clients", "openssh", "openssh"},
+	{"shellcheck", "shellcheck", "shellcheck", "ShellCheck", "shellcheck", "shellcheck"},
+	{"shfmt", "shfmt", "shfmt", "shfmt", "shfmt", "shfmt"},
+	{"socat", "socat", "socat", "socat", "socat", "socat"},
+	{"sops", "", "sops", "", "sops", "sops"},
+	{"sqlite3", "sqlite3", "sqlite", "sqlite", "sqlite", "sqlite"},
+	{"ssh", "openssh-client", "openssh-client", "openssh-clients", "openssh", "openssh"},
+	{"strace", "strace", "strace", "strace", "", "strace"},
+	{"sudo", "sudo", "sudo", "sudo", "", "sudo"},
+	{"svn", "subversion", "subversion", "subversion", "subversion", "subversion"},
+	{"tar", "tar", "tar", "tar", "gnu-tar", "gnutar"},
+	{"tmux", "tmux", "tmux", "tmux", "tmux", "tmux"},
+	{"tree", "tree", "tree", "tree", "tree", "tree"},
+	{"unzip", "unzip", "unzip", "unzip", "unzip", "unzip"},
+	{"vim", "vim", "vim", "vim-enhanced", "vim", "vim"},
+	{"watch", "procps", "procps-ng", "procps-ng", "watch", "procps"},
+	{"wget", "wget", "wget", "wget", "wget", "wget"},
+	{"xclip", "xclip", "xclip", "xclip", "", "xclip"},
+	{"xdg-open", "xdg-utils", "xdg-utils", "xdg-utils", "", "xdg-utils"},
+	{"xmllint", "libxml2-utils", "libxml2-utils", "libxml2", "libxml2", "libxml2"},
+	{"xz", "xz-utils", "xz", "xz", "xz", "xz"},
+	{"yamllint", "yamllint", "py3-yamllint", "yamllint", "yamllint", "yamllint"},
+	{"zip", "zip", "zip", "zip", "zip", "zip"},
+	{"zstd", "zstd", "zstd", "zstd", "zstd", "zstd"},
+}
+
+// coreutilsPackages provide the GNU coreutils; macOS comes with its own.
+var coreutilsPackages = map[string]string{
+	EcosystemApt: "coreutils",
+	EcosystemApk: "coreutils",
+	EcosystemDnf: "coreutils",
+	EcosystemNix: "coreutils",
+}
+
+// packageDB maps ecosystems to the packages providing commands.
+var packageDB = func() map[string]map[string]string {
+	db := make(map[string]map[string]string, len(ecosystems))
+	for _, eco := range ecosystems {
+		db[eco] = make(map[string]string)
+	}
+	for _, row := range packageRows {
+		for i, eco := range ecosystems {
+			if pkg := row[i+1]; pkg != "" {
+				db[eco][row[0]] = pkg
+			}
+		}
+	}
+	return db
+}()
+
+// Ecosystems returns the package ecosystems manifests can be generated for.
+func Ecosystems() []string {
+	return slices.Clone(ecosystems)
+}
+
+// Manifest is the packages of an ecosystem that provide the commands of a
+// scan.
+type Manifest struct {
+	Ecosystem string
+	// Packages are sorted and unique.
+	Packages []string
+	// Unmapped are the commands no package is known for.
+	Unmapped []string
+}
+
+// lookupPackage returns the package providing cmd in eco. An empty name
+// with ok set means cmd needs no package.
+func (c *Config) lookupPackage(eco, cmd string) (pkg string, ok bool) {
+	if pkg, ok := c.PackageDB[eco][cmd]; ok {
+		return pkg, true
+	}
+	if builtins[cmd] {
+		return "", true
+	}
+	if pkg, ok := packageDB[eco][cmd]; ok {
+		return pkg, true
+	}
+	if coreutils[cmd] {
+		return coreutilsPackages[eco], true
+	}
+	return "", false
+}
+
+// Manifest returns the packages of ecosystem providing the commands of r.
+// Packages are looked up in PackageDB first, then in the built-in database.
+func (r ScanResult) Manifest(c *Config, ecosystem string) (*Manifest, error) {
+	if !slices.Contains(ecosystems, ecosystem) {
+		return nil, fmt.Errorf("unknown ecosystem %q (expected one of %s)", ecosystem, strings.Join(ecosystems, ", "))
+	}
+
+	cmds := make(map[string]bool)
+	for _, occs := range r {
+		for cmd := range occs {
+			cmds[cmd] = true
+		}
+	}
+
+	m := &Manifest{Ecosystem: ecosystem}
+	pkgs := make(map[string]bool)
+	for _, cmd := range slices.Sorted(maps.Keys(cmds)) {
+		pkg, ok := c.lookupPackage(ecosystem, cmd)
+		if !ok {
+			m.Unmapped = append(m.Unmapped, cmd)
+		} else if pkg != "" {
+			pkgs[pkg] = true
+		}
+	}
+	m.Packages = slices.Sorted(maps.Keys(pkgs))
+	return m, nil
+}
+
+// String renders the manifest: an install command for apt, apk and dnf, a
+// Brewfile for brew, and a shell.nix for nix. Unmapped commands are listed in
+// a comment.
+func (m *Manifest) String() string {
+	var sb strings.Builder
+	install := func(cmd string) {
+		if len(m.Packages) > 0 {
+			fmt.Fprintf(&sb, "%s %s\n", cmd, strings.Join(m.Packages, " "))
+		}
+	}
+
+	switch m.Ecosystem {
+	case EcosystemApt:
+		install("apt-get install -y --no-install-recommends")
+	case EcosystemApk:
+		install("apk add --no-cache")
+	case EcosystemDnf:
+		install("dnf install -y")
+	case EcosystemBrew:
+		for _, pkg := range m.Packages {
+			fmt.Fprintf(&sb, "brew %q\n", pkg)
+		}
+	case EcosystemNix:
+		sb.WriteString("{ pkgs ? import <nixpkgs> { } }:\n\npkgs.mkShell {\n  packages = with pkgs; [\n")
+		for _, pkg := range m.Packages {
+			fmt.Fprintf(&sb, "    %s\n", pkg)
+		}
+		sb.WriteString("  ];\n}\n")
+	}
+
+	if len(m.Unmapped) > 0 {
+		fmt.Fprintf(&sb, "# No package known for: %s\n", strings.Join(m.Unmapped, ", "))
+	}
+	return sb.String()
+}
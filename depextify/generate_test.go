@@ -0,0 +1,75 @@
+package depextify
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResult_Manifest(t *testing.T) {
+	res := ScanResult{
+		"build.sh": {
+			"curl":       {{Line: 1}},
+			"jq":         {{Line: 2}},
+			"rg":         {{Line: 3}},
+			"cd":         {{Line: 4}},
+			"sort":       {{Line: 5}},
+			"my-tool":    {{Line: 6}},
+			"pip":        {{Line: 7}},
+			"pip3":       {{Line: 8}},
+			"shellcheck": {{Line: 9}},
+		},
+		"Makefile": {"make": {{Line: 1}}},
+	}
+
+	t.Run("apt", func(t *testing.T) {
+		m, err := res.Manifest(&Config{}, EcosystemApt)
+		require.NoError(t, err)
+		// Built-ins need no package, and packages are not repeated
+		require.Equal(t, []string{"coreutils", "curl", "jq", "make", "python3-pip", "ripgrep", "shellcheck"}, m.Packages)
+		require.Equal(t, []string{"my-tool"}, m.Unmapped)
+		require.Equal(t, "apt-get install -y --no-install-recommends coreutils curl jq make python3-pip ripgrep shellcheck\n# No package known for: my-tool\n", m.String())
+	})
+
+	t.Run("overrides", func(t *testing.T) {
+		config := &Config{PackageDB: map[string]map[string]string{
+			EcosystemApk: {"my-tool": "my-tool-bin", "curl": "", "rg": "ripgrep-all"},
+		}}
+		m, err := res.Manifest(config, EcosystemApk)
+		require.NoError(t, err)
+		require.Equal(t, []string{"coreutils", "jq", "make", "my-tool-bin", "py3-pip", "ripgrep-all", "shellcheck"}, m.Packages)
+		require.Empty(t, m.Unmapped)
+		require.Equal(t, "apk add --no-cache coreutils jq make my-tool-bin py3-pip ripgrep-all shellcheck\n", m.String())
+	})
+
+	t.Run("brew", func(t *testing.T) {
+		m, err := ScanResult{"a.sh": {"jq": {{Line: 1}}, "tar": {{Line: 2}}, "sort": {{Line: 3}}}}.Manifest(&Config{}, EcosystemBrew)
+		require.NoError(t, err)
+		require.Equal(t, "brew \"gnu-tar\"\nbrew \"jq\"\n", m.String())
+	})
+
+	t.Run("nix", func(t *testing.T) {
+		m, err := ScanResult{"a.sh": {"jq": {{Line: 1}}, "make": {{Line: 2}}}}.Manifest(&Config{}, EcosystemNix)
+		require.NoError(t, err)
+		require.Equal(t, "{ pkgs ? import <nixpkgs> { } }:\n\npkgs.mkShell {\n  packages = with pkgs; [\n    gnumake\n    jq\n  ];\n}\n", m.String())
+	})
+
+	t.Run("dnf without packages", func(t *testing.T) {
+		m, err := ScanResult{"a.sh": {"my-tool": {{Line: 1}}}}.Manifest(&Config{}, EcosystemDnf)
+		require.NoError(t, err)
+		require.Equal(t, "# No package known for: my-tool\n", m.String())
+	})
+
+	t.Run("unknown ecosystem", func(t *testing.T) {
+		_, err := res.Manifest(&Config{}, "pacman")
+		require.Error(t, err)
+	})
+}
+
+func TestPackageDB(t *testing.T) {
+	seen := make(map[string]bool)
+	for _, row := range packageRows {
+		require.False(t, seen[row[0]], "duplicate command %s", row[0])
+		seen[row[0]] = true
+	}
+}
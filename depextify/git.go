@@ -0,0 +1,484 @@
+package depextify
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type (
+	// gitTreeFS is the fs.FS of the tree of a commit, read from the local
+	// repository instead of a checkout. Names are relative to the top of the
+	// repository.
+	gitTreeFS struct {
+		dir     string
+		rev     string
+		entries map[string]*gitEntry
+
+		// cat-file --batch process the blobs are read from
+		mu    sync.Mutex
+		cmd   *exec.Cmd
+		stdin io.WriteCloser
+		out   *bufio.Reader
+	}
+
+	gitEntry struct {
+		name string
+		mode fs.FileMode
+		oid  string
+		size int64
+		// Names of the entries of a directory, sorted
+		children []string
+	}
+
+	gitFileInfo struct{ e *gitEntry }
+
+	gitFile struct {
+		*bytes.Reader
+		info gitFileInfo
+	}
+
+	gitDir struct {
+		fsys    *gitTreeFS
+		info    gitFileInfo
+		dirPath string
+		offset  int
+	}
+
+	// fileSet holds the files a scan is restricted to, along with the
+	// directories leading to them.
+	fileSet struct {
+		files map[string]bool
+		dirs  map[string]bool
+	}
+)
+
+// maxSymlinks bounds the symlinks followed to resolve a path, as in Linux.
+const maxSymlinks = 40
+
+// runGit runs git in dir and returns its output.
+func runGit(dir string, args ...string) ([]byte, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("git %s: %s", args[0], msg)
+		}
+		return nil, fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return out, nil
+}
+
+// splitNUL splits the -z output of git.
+func splitNUL(out []byte) []string {
+	var names []string
+	for name := range strings.SplitSeq(string(out), "\x00") {
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func (fi gitFileInfo) Name() string       { return path.Base(fi.e.name) }
+func (fi gitFileInfo) Size() int64        { return fi.e.size }
+func (fi gitFileInfo) Mode() fs.FileMode  { return fi.e.mode }
+func (fi gitFileInfo) ModTime() time.Time { return time.Time{} }
+func (fi gitFileInfo) IsDir() bool        { return fi.e.mode.IsDir() }
+func (fi gitFileInfo) Sys() any           { return nil }
+
+func (f *gitFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *gitFile) Close() error               { return nil }
+
+func (d *gitDir) Stat() (fs.FileInfo, error) { return d.info, nil }
+func (d *gitDir) Close() error               { return nil }
+func (d *gitDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.dirPath, Err: errors.New("is a directory")}
+}
+
+func (d *gitDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	children := d.info.e.children[d.offset:]
+	if n > 0 && len(children) > n {
+		children = children[:n]
+	}
+	if n > 0 && len(children) == 0 {
+		return nil, io.EOF
+	}
+	d.offset += len(children)
+	return d.fsys.dirEntries(d.dirPath, children), nil
+}
+
+// openGitTree lists the tree of rev in the repository of the working directory.
+func openGitTree(rev string) (*gitTreeFS, error) {
+	top, err := runGit("", "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, err
+	}
+	dir := strings.TrimSpace(string(top))
+
+	out, err := runGit(dir, "ls-tree", "-r", "-t", "-z", "--long", "--full-tree", rev)
+	if err != nil {
+		return nil, err
+	}
+
+	fsys := &gitTreeFS{
+		dir:     dir,
+		rev:     rev,
+		entries: map[string]*gitEntry{".": {name: ".", mode: fs.ModeDir | 0o755}},
+	}
+	for _, line := range splitNUL(out) {
+		// <mode> SP <type> SP <object> SP+ <size> TAB <path>
+		meta, name, ok := strings.Cut(line, "\t")
+		fields := strings.Fields(meta)
+		if !ok || len(fields) != 4 {
+			return nil, fmt.Errorf("git ls-tree: unexpected line %q", line)
+		}
+
+		e := &gitEntry{name: name, oid: fields[2]}
+		switch fields[0] {
+		case "040000":
+			e.mode = fs.ModeDir | 0o755
+		case "100755":
+			e.mode = 0o755
+		case "100644":
+			e.mode = 0o644
+		case "120000":
+			e.mode = fs.ModeSymlink | 0o777
+		default:
+			// Submodules
+			continue
+		}
+		e.size, _ = strconv.ParseInt(fields[3], 10, 64)
+
+		fsys.entries[name] = e
+		parent := fsys.entries[path.Dir(name)]
+		if parent == nil {
+			return nil, fmt.Errorf("git ls-tree: %s listed before its directory", name)
+		}
+		parent.children = append(parent.children, path.Base(name))
+	}
+	for _, e := range fsys.entries {
+		slices.Sort(e.children)
+	}
+	return fsys, nil
+}
+
+// revPath returns the path in the repository of a target given relative to
+// the working directory, or absolute.
+func (fsys *gitTreeFS) revPath(target string) (string, error) {
+	var rel string
+	if filepath.IsAbs(target) {
+		r, err := filepath.Rel(fsys.dir, target)
+		if err != nil {
+			return "", err
+		}
+		rel = filepath.ToSlash(r)
+	} else {
+		prefix, err := runGit("", "rev-parse", "--show-prefix")
+		if err != nil {
+			return "", err
+		}
+		rel = path.Join(strings.TrimSpace(string(prefix)), filepath.ToSlash(target))
+	}
+	rel = path.Clean(rel)
+	if rel == ".." || strings.HasPrefix(rel, "../") {
+		return "", fmt.Errorf("%s is outside the repository", target)
+	}
+	return rel, nil
+}
+
+// readBlob returns the content of a blob.
+func (fsys *gitTreeFS) readBlob(oid string) ([]byte, error) {
+	fsys.mu.Lock()
+	defer fsys.mu.Unlock()
+
+	if fsys.cmd == nil {
+		cmd := exec.Command("git", "cat-file", "--batch")
+		cmd.Dir = fsys.dir
+		stdin, err := cmd.StdinPipe()
+		if err != nil {
+			return nil, err
+		}
+		stdout, err := cmd.StdoutPipe()
+		if err != nil {
+			return nil, err
+		}
+		if err := cmd.Start(); err != nil {
+			return nil, err
+		}
+		fsys.cmd, fsys.stdin, fsys.out = cmd, stdin, bufio.NewReader(stdout)
+	}
+
+	if _, err := fmt.Fprintln(fsys.stdin, oid); err != nil {
+		return nil, err
+	}
+	// <oid> SP <type> SP <size> LF <content> LF
+	header, err := fsys.out.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	fields := strings.Fields(header)
+	if len(fields) != 3 {
+		return nil, fmt.Errorf("git cat-file: %s", strings.TrimSpace(header))
+	}
+	size, err := strconv.Atoi(fields[2])
+	if err != nil {
+		return nil, err
+	}
+	content := make([]byte, size+1)
+	if _, err := io.ReadFull(fsys.out, content); err != nil {
+		return nil, err
+	}
+	return content[:size], nil
+}
+
+// Close stops the git process reading blobs.
+func (fsys *gitTreeFS) Close() error {
+	fsys.mu.Lock()
+	defer fsys.mu.Unlock()
+	if fsys.cmd == nil {
+		return nil
+	}
+	_ = fsys.stdin.Close()
+	err := fsys.cmd.Wait()
+	fsys.cmd = nil
+	return err
+}
+
+// resolve returns the entry of name, following symlinks within the tree.
+func (fsys *gitTreeFS) resolve(op, name string, follow bool) (*gitEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+
+	for range maxSymlinks {
+		// Resolve the directories leading to name first
+		dir, base := path.Dir(name), path.Base(name)
+		if dir != "." {
+			d, err := fsys.resolve(op, dir, true)
+			if err != nil {
+				return nil, err
+			}
+			name = path.Join(d.name, base)
+		}
+
+		e, ok := fsys.entries[name]
+		if !ok {
+			return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+		}
+		if e.mode&fs.ModeSymlink == 0 || !follow {
+			return e, nil
+		}
+
+		target, err := fsys.readBlob(e.oid)
+		if err != nil {
+			return nil, &fs.PathError{Op: op, Path: name, Err: err}
+		}
+		name = path.Join(path.Dir(name), string(target))
+		if path.IsAbs(string(target)) || !fs.ValidPath(name) {
+			// Outside of the tree
+			return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+		}
+	}
+	return nil, &fs.PathError{Op: op, Path: name, Err: errors.New("too many levels of symbolic links")}
+}
+
+func (fsys *gitTreeFS) dirEntries(dir string, names []string) []fs.DirEntry {
+	entries := make([]fs.DirEntry, 0, len(names))
+	for _, name := range names {
+		entries = append(entries, fs.FileInfoToDirEntry(gitFileInfo{fsys.entries[path.Join(dir, name)]}))
+	}
+	return entries
+}
+
+func (fsys *gitTreeFS) Open(name string) (fs.File, error) {
+	e, err := fsys.resolve("open", name, true)
+	if err != nil {
+		return nil, err
+	}
+	if e.mode.IsDir() {
+		return &gitDir{fsys: fsys, info: gitFileInfo{e}, dirPath: e.name}, nil
+	}
+	content, err := fsys.readBlob(e.oid)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return &gitFile{Reader: bytes.NewReader(content), info: gitFileInfo{e}}, nil
+}
+
+func (fsys *gitTreeFS) Stat(name string) (fs.FileInfo, error) {
+	e, err := fsys.resolve("stat", name, true)
+	if err != nil {
+		return nil, err
+	}
+	return gitFileInfo{e}, nil
+}
+
+func (fsys *gitTreeFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	e, err := fsys.resolve("readdir", name, true)
+	if err != nil {
+		return nil, err
+	}
+	if !e.mode.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errors.New("not a directory")}
+	}
+	return fsys.dirEntries(e.name, e.children), nil
+}
+
+func (fsys *gitTreeFS) ReadFile(name string) ([]byte, error) {
+	e, err := fsys.resolve("read", name, true)
+	if err != nil {
+		return nil, err
+	}
+	if e.mode.IsDir() {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: errors.New("is a directory")}
+	}
+	return fsys.readBlob(e.oid)
+}
+
+// newFileSet returns the set of the given files, slash-separated and
+// relative to base, as paths of fsys.
+func newFileSet(fsys fs.FS, base string, names []string) *fileSet {
+	set := &fileSet{files: make(map[string]bool), dirs: make(map[string]bool)}
+	for _, name := range names {
+		parts := strings.Split(name, "/")
+		set.files[joinPath(fsys, append([]string{base}, parts...)...)] = true
+		for i := 1; i < len(parts); i++ {
+			set.dirs[joinPath(fsys, append([]string{base}, parts[:i]...)...)] = true
+		}
+	}
+	return set
+}
+
+// intersect returns the files both in s and other.
+func (s *fileSet) intersect(fsys fs.FS, other *fileSet) *fileSet {
+	set := &fileSet{files: make(map[string]bool), dirs: make(map[string]bool)}
+	for file := range s.files {
+		if !other.files[file] {
+			continue
+		}
+		set.files[file] = true
+		for dir := dirOf(fsys, file); s.dirs[dir] && !set.dirs[dir]; dir = dirOf(fsys, dir) {
+			set.dirs[dir] = true
+		}
+	}
+	return set
+}
+
+func dirOf(fsys fs.FS, p string) string {
+	if _, ok := fsys.(osFS); ok {
+		return filepath.Dir(p)
+	}
+	return path.Dir(p)
+}
+
+// gitSelection returns the files under root that GitTracked and Since
+// restrict the scan to, or nil when they are not set.
+func (c *Config) gitSelection(fsys fs.FS, root string, isDir bool) (*fileSet, error) {
+	if !c.GitTracked && c.Since == "" {
+		return nil, nil
+	}
+
+	switch fsys := fsys.(type) {
+	case osFS:
+		// git lists paths relative to the directory it runs in
+		dir := root
+		if !isDir {
+			dir = filepath.Dir(root)
+		}
+
+		var set *fileSet
+		if c.GitTracked {
+			out, err := runGit(dir, "ls-files", "-z")
+			if err != nil {
+				return nil, err
+			}
+			set = newFileSet(osFS{}, dir, splitNUL(out))
+		}
+		if c.Since != "" {
+			changed, err := runGit(dir, "diff", "--name-only", "--relative", "--diff-filter=d", "-z", c.Since, "--")
+			if err != nil {
+				return nil, err
+			}
+			// Files not committed yet are new since any commit
+			untracked, err := runGit(dir, "ls-files", "-z", "--others", "--exclude-standard")
+			if err != nil {
+				return nil, err
+			}
+			since := newFileSet(osFS{}, dir, append(splitNUL(changed), splitNUL(untracked)...))
+			if set == nil {
+				set = since
+			} else {
+				set = set.intersect(osFS{}, since)
+			}
+		}
+		return set, nil
+
+	case *gitTreeFS:
+		// Every file of a commit is tracked
+		if c.Since == "" {
+			return nil, nil
+		}
+		out, err := runGit(fsys.dir, "diff", "--name-only", "--diff-filter=d", "-z", c.Since, fsys.rev, "--")
+		if err != nil {
+			return nil, err
+		}
+		return newFileSet(fsys, ".", splitNUL(out)), nil
+	}
+
+	return nil, errors.New("GitTracked and Since need a git working tree")
+}
+
+// scanRev scans target as of the commit Rev. Results are keyed like those
+// of a scan of the working tree, by paths under target.
+func (c *Config) scanRev(target string) (ScanResult, error) {
+	fsys, err := openGitTree(c.Rev)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = fsys.Close() }()
+
+	root, err := fsys.revPath(target)
+	if err != nil {
+		return nil, err
+	}
+	res, err := c.ScanFS(fsys, root)
+	if err != nil {
+		return nil, err
+	}
+
+	target = filepath.Clean(target)
+	rekeyed := make(ScanResult, len(res))
+	for name, cmds := range res {
+		rekeyed[workingPath(target, root, name)] = cmds
+	}
+	return rekeyed, nil
+}
+
+// workingPath returns the path under target of name, a path in the tree
+// under root, the path of target in the tree.
+func workingPath(target, root, name string) string {
+	if root == "." {
+		return filepath.Join(target, filepath.FromSlash(name))
+	}
+	rest := strings.TrimPrefix(name, root)
+	if after, ok := strings.CutPrefix(rest, "/"); ok {
+		return filepath.Join(target, filepath.FromSlash(after))
+	}
+	// The target itself, or a member of it when it is an archive
+	return target + filepath.FromSlash(rest)
+}
@@ -0,0 +1,164 @@
+package depextify
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// gitRepo creates a repository in a temporary directory and returns a
+// function running git in it.
+func gitRepo(t *testing.T) (string, func(args ...string) string) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	dir := t.TempDir()
+	git := func(args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com",
+			"GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com",
+			"GIT_CONFIG_GLOBAL=/dev/null", "GIT_CONFIG_NOSYSTEM=1",
+		)
+		out, err := cmd.CombinedOutput()
+		require.NoError(t, err, string(out))
+		return string(out)
+	}
+	git("init", "-q", "-b", "main")
+	return dir, git
+}
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		p := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0755))
+		require.NoError(t, os.WriteFile(p, []byte(content), 0600))
+	}
+}
+
+func TestScan_Git(t *testing.T) {
+	dir, git := gitRepo(t)
+	writeFiles(t, dir, map[string]string{
+		".gitignore":           "build/\n",
+		"scripts/deploy.sh":    "rsync -a dist/ host:\n",
+		"scripts/old.sh":       "wget example.com\n",
+		"sub/Makefile":         "all:\n\tgo build\n",
+		"build/generated.sh":   "protoc x\n",
+		"scripts/release.sh":   "gh release create\n",
+		"scripts/tools/lint":   "#!/bin/sh\nshellcheck *.sh\n",
+		"scripts/unchanged.sh": "jq . x\n",
+	})
+	require.NoError(t, os.Symlink("deploy.sh", filepath.Join(dir, "scripts/link.sh")))
+	git("add", ".gitignore", "scripts/deploy.sh", "scripts/old.sh", "sub/Makefile", "scripts/tools/lint", "scripts/unchanged.sh", "scripts/link.sh")
+	git("commit", "-q", "-m", "first")
+	git("tag", "v1")
+
+	// Changes after v1
+	writeFiles(t, dir, map[string]string{
+		"scripts/deploy.sh": "aws s3 sync dist/ s3://bucket\n",
+		"sub/new.sh":        "terraform apply\n",
+	})
+	git("rm", "-q", "scripts/old.sh")
+	git("add", "scripts/deploy.sh", "sub/new.sh")
+	git("commit", "-q", "-m", "second")
+	// scripts/release.sh stays untracked
+
+	p := func(name string) string { return filepath.Join(dir, filepath.FromSlash(name)) }
+
+	t.Run("tracked files", func(t *testing.T) {
+		res, err := (&Config{GitTracked: true}).Scan(dir)
+		require.NoError(t, err)
+		require.Contains(t, res[p("scripts/deploy.sh")], "aws")
+		require.Contains(t, res[p("scripts/link.sh")], "aws")
+		require.Contains(t, res[p("scripts/tools/lint")], "shellcheck")
+		require.Contains(t, res[p("sub/Makefile")], "go")
+		require.NotContains(t, res, p("build/generated.sh"))
+		require.NotContains(t, res, p("scripts/release.sh"))
+	})
+
+	t.Run("tracked files of a subdirectory", func(t *testing.T) {
+		res, err := (&Config{GitTracked: true}).Scan(p("scripts"))
+		require.NoError(t, err)
+		require.Contains(t, res, p("scripts/deploy.sh"))
+		require.NotContains(t, res, p("scripts/release.sh"))
+
+		res, err = (&Config{GitTracked: true}).Scan(p("scripts/release.sh"))
+		require.NoError(t, err)
+		require.Empty(t, res)
+	})
+
+	t.Run("since", func(t *testing.T) {
+		res, err := (&Config{Since: "v1"}).Scan(dir)
+		require.NoError(t, err)
+		require.Contains(t, res[p("scripts/deploy.sh")], "aws")
+		require.Contains(t, res[p("sub/new.sh")], "terraform")
+		// Not committed yet
+		require.Contains(t, res[p("scripts/release.sh")], "gh")
+		require.NotContains(t, res, p("scripts/unchanged.sh"))
+		require.NotContains(t, res, p("sub/Makefile"))
+
+		res, err = (&Config{Since: "v1", GitTracked: true}).Scan(dir)
+		require.NoError(t, err)
+		require.NotContains(t, res, p("scripts/release.sh"))
+		require.Contains(t, res, p("sub/new.sh"))
+	})
+
+	t.Run("rev", func(t *testing.T) {
+		t.Chdir(dir)
+
+		res, err := (&Config{Rev: "v1"}).Scan(".")
+		require.NoError(t, err)
+		require.Equal(t, []Occurrence{{Line: 1, Col: 1, Len: 5, FullLine: "rsync -a dist/ host:"}}, res["scripts/deploy.sh"]["rsync"])
+		require.Contains(t, res["scripts/link.sh"], "rsync")
+		require.Contains(t, res["scripts/old.sh"], "wget")
+		require.Contains(t, res["scripts/tools/lint"], "shellcheck")
+		require.NotContains(t, res, "sub/new.sh")
+		require.NotContains(t, res, "scripts/release.sh")
+
+		// Targets are relative to the working directory
+		t.Chdir(filepath.Join(dir, "sub"))
+		res, err = (&Config{Rev: "main"}).Scan(".")
+		require.NoError(t, err)
+		require.Contains(t, res["new.sh"], "terraform")
+		require.NotContains(t, res, "sub/new.sh")
+
+		// and so are results, as in a scan of the working tree
+		res, err = (&Config{Rev: "main"}).Scan("../scripts/deploy.sh")
+		require.NoError(t, err)
+		require.Contains(t, res[filepath.FromSlash("../scripts/deploy.sh")], "aws")
+
+		res, err = (&Config{Rev: "main"}).Scan("../scripts")
+		require.NoError(t, err)
+		require.Contains(t, res[filepath.FromSlash("../scripts/tools/lint")], "shellcheck")
+
+		res, err = (&Config{Rev: "main"}).Scan(filepath.Join(dir, "sub"))
+		require.NoError(t, err)
+		require.Contains(t, res[filepath.Join(dir, "sub", "new.sh")], "terraform")
+
+		_, err = (&Config{Rev: "main"}).Scan("../..")
+		require.Error(t, err)
+	})
+
+	t.Run("rev and since", func(t *testing.T) {
+		t.Chdir(dir)
+		res, err := (&Config{Rev: "main", Since: "v1"}).Scan(".")
+		require.NoError(t, err)
+		require.Contains(t, res, "scripts/deploy.sh")
+		require.Contains(t, res, "sub/new.sh")
+		require.Len(t, res, 2)
+	})
+
+	t.Run("unknown rev", func(t *testing.T) {
+		t.Chdir(dir)
+		_, err := (&Config{Rev: "nope"}).Scan(".")
+		require.Error(t, err)
+	})
+}
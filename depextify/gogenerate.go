@@ -0,0 +1,179 @@
+package depextify
+
+import (
+	"bufio"
+	"bytes"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// GoGenerateExtractor extracts generator commands from //go:generate directives.
+type GoGenerateExtractor struct{}
+
+const goGeneratePrefix = "//go:generate"
+
+var reMajorVersion = regexp.MustCompile(`^v[0-9]+$`)
+
+// generateWord is a word of a //go:generate directive and its byte offset in the line.
+type generateWord struct {
+	value  string
+	offset int
+	raw    string
+}
+
+// splitGenerateLine splits the arguments of a //go:generate directive as the
+// go tool does: on spaces and tabs, with double-quoted Go strings as single words.
+func splitGenerateLine(line string, offset int) []generateWord {
+	var words []generateWord
+	for i := offset; i < len(line); {
+		if line[i] == ' ' || line[i] == '\t' {
+			i++
+			continue
+		}
+
+		start := i
+		if line[i] == '"' {
+			for i++; i < len(line) && line[i] != '"'; i++ {
+				if line[i] == '\\' {
+					i++
+				}
+			}
+			if i < len(line) {
+				i++
+			}
+			raw := line[start:i]
+			value, err := strconv.Unquote(raw)
+			if err != nil {
+				// go generate rejects the whole directive.
+				return nil
+			}
+			words = append(words, generateWord{value: value, offset: start, raw: raw})
+			continue
+		}
+
+		for i < len(line) && line[i] != ' ' && line[i] != '\t' {
+			i++
+		}
+		words = append(words, generateWord{value: line[start:i], offset: start, raw: line[start:i]})
+	}
+	return words
+}
+
+// goGenerator returns the generator run by go with args, "go run" of a
+// remote package or "go tool", and the index of its package or name in args.
+// Generators in the module are run by go itself.
+func goGenerator(args []string) (string, int, bool) {
+	if len(args) == 0 || (args[0] != "run" && args[0] != "tool") {
+		return "", 0, false
+	}
+	for i := 1; i < len(args); i++ {
+		if strings.HasPrefix(args[i], "-") {
+			continue
+		}
+		if args[0] == "tool" {
+			return args[i], i, true
+		}
+
+		pkg, _, _ := strings.Cut(args[i], "@")
+		// Remote packages start with a domain, unlike files and local packages
+		host, _, found := strings.Cut(pkg, "/")
+		if !found || !strings.Contains(host, ".") || strings.HasPrefix(pkg, ".") {
+			return "", 0, false
+		}
+		name := path.Base(pkg)
+		// Major version suffixes, e.g. example.com/cmd/gen/v2
+		if reMajorVersion.MatchString(name) && path.Dir(pkg) != host {
+			name = path.Base(path.Dir(pkg))
+		}
+		return name, i, true
+	}
+	return "", 0, false
+}
+
+func (e *GoGenerateExtractor) Extract(content []byte) (map[string][]posInfo, error) {
+	results := make(map[string][]posInfo)
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+
+	// -command aliases defined so far: name -> expansion
+	aliases := make(map[string][]string)
+	lineNum := 0
+
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+
+		// The directive must start the line, with no space after "//".
+		rest, ok := strings.CutPrefix(line, goGeneratePrefix)
+		if !ok || (rest != "" && rest[0] != ' ' && rest[0] != '\t') {
+			continue
+		}
+
+		words := splitGenerateLine(line, len(goGeneratePrefix))
+		if len(words) == 0 {
+			continue
+		}
+
+		if words[0].value == "-command" {
+			if len(words) < 3 {
+				continue
+			}
+			expansion := make([]string, 0, len(words)-2)
+			for _, w := range words[2:] {
+				expansion = append(expansion, w.value)
+			}
+			aliases[words[1].value] = expansion
+			continue
+		}
+
+		// argv is the command run; its words past the expansion of an
+		// alias are words of the line.
+		argv := []string{words[0].value}
+		expansion, isAlias := aliases[words[0].value]
+		if isAlias {
+			argv = slices.Clone(expansion)
+		}
+		for _, w := range words[1:] {
+			argv = append(argv, w.value)
+		}
+		wordOf := func(i int) generateWord {
+			return words[max(i-len(argv)+len(words), 0)]
+		}
+
+		cmd, at := argv[0], wordOf(0)
+		if cmd == "go" {
+			if name, i, ok := goGenerator(argv[1:]); ok {
+				cmd, at = name, wordOf(i+1)
+			}
+		}
+
+		results[cmd] = append(results[cmd], posInfo{
+			line: uint(lineNum),
+			col:  uint(at.offset + 1),
+			len:  uint(len(at.raw)),
+		})
+
+		// //go:generate sh -c "..." runs a script
+		args := words[1:]
+		if isAlias || !reShellName.MatchString(path.Base(argv[0])) || len(args) < 2 || args[0].value != "-c" || !strings.HasPrefix(args[1].raw, `"`) {
+			continue
+		}
+		// Parse the quoted script as written so positions match the line.
+		script := args[1].raw[1 : len(args[1].raw)-1]
+		cmds, err := analyzeShellCode(script)
+		if err != nil {
+			continue
+		}
+		for c, infos := range cmds {
+			for _, info := range infos {
+				info.line = uint(lineNum)
+				info.col += uint(args[1].offset + 1)
+				results[c] = append(results[c], info)
+			}
+		}
+	}
+
+	return results, nil
+}
@@ -0,0 +1,64 @@
+package depextify
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtractGoGenerate(t *testing.T) {
+	content := `package db
+
+//go:generate stringer -type=Pill
+//go:generate -command mock go run go.uber.org/mock/mockgen
+//go:generate mock -source=store.go -destination=mock_store.go
+//go:generate -command gorun go run
+//go:generate gorun github.com/sqlc-dev/sqlc/cmd/sqlc generate
+//go:generate go run golang.org/x/tools/cmd/goimports@latest -w .
+//go:generate go run -mod=mod github.com/99designs/gqlgen/v2 generate
+//go:generate go run ./internal/gen
+//go:generate go tool yacc -o expr.go expr.y
+//go:generate sh -c "protoc --go_out=. api.proto && buf lint"
+//go:generate "sqlc" generate
+// go:generate ignored because of the space
+//go:generatefoo ignored too
+	//go:generate ignored when indented
+`
+	extractor := &GoGenerateExtractor{}
+	res, err := extractor.Extract([]byte(content))
+	require.NoError(t, err)
+
+	require.Equal(t, map[string][]posInfo{
+		"stringer": {{line: 3, col: 15, len: 8}},
+		// Aliases and go run resolve to the generator they run
+		"mockgen":   {{line: 5, col: 15, len: 4}},
+		"sqlc":      {{line: 7, col: 21, len: 33}, {line: 13, col: 15, len: 6}},
+		"goimports": {{line: 8, col: 22, len: 39}},
+		"gqlgen":    {{line: 9, col: 31, len: 30}},
+		"go":        {{line: 10, col: 15, len: 2}},
+		"yacc":      {{line: 11, col: 23, len: 4}},
+		"sh":        {{line: 12, col: 15, len: 2}},
+		"protoc":    {{line: 12, col: 22, len: 6}},
+		"buf":       {{line: 12, col: 53, len: 3}},
+	}, res)
+}
+
+func TestMultiExtractor(t *testing.T) {
+	content := `package main
+
+import "os/exec"
+
+//go:generate stringer -type=Kind
+
+func main() { _ = exec.Command("git").Run() }
+`
+	res, err := GetExtractor("main.go").Extract([]byte(content))
+	require.NoError(t, err)
+	require.Contains(t, res, "stringer")
+	require.Contains(t, res, "git")
+
+	// go/ast rejects the file, but directives are still found.
+	res, err = GetExtractor("broken.go").Extract([]byte("//go:generate mockgen\npackage\n"))
+	require.NoError(t, err)
+	require.Contains(t, res, "mockgen")
+}
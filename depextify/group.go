@@ -0,0 +1,181 @@
+package depextify
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// GroupByFile lists commands per file; it is the default.
+	GroupByFile = "file"
+	// GroupByCommand lists files per command.
+	GroupByCommand = "command"
+
+	// SortByCount puts the most used commands first; it is the default.
+	SortByCount = "count"
+	// SortByName sorts commands by name.
+	SortByName = "name"
+)
+
+type (
+	// CommandUsage is where a command is used across the scanned files.
+	CommandUsage struct {
+		Command string
+		Count   int
+		Files   []FileUsage
+	}
+
+	// FileUsage is the usage of a command in a file.
+	FileUsage struct {
+		Path        string
+		Count       int
+		Occurrences []Occurrence `json:",omitempty" yaml:",omitempty"`
+	}
+)
+
+// ByCommand inverts the result: it returns the files each command is used in,
+// sorted by sortBy (SortByCount or SortByName). Files are sorted the same way.
+func (r ScanResult) ByCommand(sortBy string) []CommandUsage {
+	byCmd := make(map[string]*CommandUsage)
+	for path, cmds := range r {
+		for cmd, occs := range cmds {
+			u, ok := byCmd[cmd]
+			if !ok {
+				u = &CommandUsage{Command: cmd}
+				byCmd[cmd] = u
+			}
+			u.Count += len(occs)
+			u.Files = append(u.Files, FileUsage{Path: path, Count: len(occs), Occurrences: occs})
+		}
+	}
+
+	usages := make([]CommandUsage, 0, len(byCmd))
+	for _, u := range byCmd {
+		slices.SortFunc(u.Files, func(a, b FileUsage) int {
+			if sortBy != SortByName {
+				if c := cmp.Compare(b.Count, a.Count); c != 0 {
+					return c
+				}
+			}
+			return cmp.Compare(a.Path, b.Path)
+		})
+		usages = append(usages, *u)
+	}
+	slices.SortFunc(usages, func(a, b CommandUsage) int {
+		if sortBy != SortByName {
+			if c := cmp.Compare(b.Count, a.Count); c != 0 {
+				return c
+			}
+		}
+		return cmp.Compare(a.Command, b.Command)
+	})
+	return usages
+}
+
+// byCommand returns the inverted result for JSON and YAML. Occurrences are
+// only included with ShowPos.
+func (r ScanResult) byCommand(c *Config) []CommandUsage {
+	usages := r.ByCommand(c.SortBy)
+	if !c.ShowPos {
+		for _, u := range usages {
+			for i := range u.Files {
+				u.Files[i].Occurrences = nil
+			}
+		}
+	}
+	return usages
+}
+
+// formatByCommand is Format for GroupByCommand.
+func (r ScanResult) formatByCommand(c *Config) string {
+	var sb strings.Builder
+
+	lineWidth := 0
+	if c.ShowPos {
+		maxLine := 0
+		for _, cmds := range r {
+			for _, occs := range cmds {
+				for _, occ := range occs {
+					maxLine = max(maxLine, occ.Line)
+				}
+			}
+		}
+		lineWidth = len(fmt.Sprintf("%d", maxLine))
+	}
+
+	for _, u := range r.ByCommand(c.SortBy) {
+		cmd := u.Command
+		if c.UseColor {
+			cmd = colorBold + cmd + colorReset
+		}
+		colon := ":"
+		if c.UseColor {
+			colon = colorYellow + ":" + colorReset
+		}
+		files := "files"
+		if len(u.Files) == 1 {
+			files = "file"
+		}
+		summary := fmt.Sprintf(" %d in %d %s", u.Count, len(u.Files), files)
+		if c.UseColor {
+			summary = colorGreen + summary + colorReset
+		}
+		fmt.Fprintf(&sb, "%s%s%s\n", cmd, colon, summary)
+
+		for _, f := range u.Files {
+			path := f.Path
+			if c.UseColor {
+				path = colorCyan + path + colorReset
+			}
+			if !c.ShowCount && !c.ShowPos {
+				fmt.Fprintf(&sb, "  %s\n", path)
+				continue
+			}
+			suffix := colon
+			if c.ShowCount {
+				count := fmt.Sprintf(" %d", f.Count)
+				if c.UseColor {
+					count = colorGreen + count + colorReset
+				}
+				suffix += count
+			}
+			fmt.Fprintf(&sb, "  %s%s\n", path, suffix)
+
+			if c.ShowPos {
+				for _, occ := range f.Occurrences {
+					ln := fmt.Sprintf("%*d", lineWidth, occ.Line)
+					if c.UseColor {
+						ln = colorGreen + ln + colorReset
+					}
+					content := strings.TrimSpace(occ.FullLine)
+					if occ.Context != "" {
+						content = "[" + occ.Context + "]  " + content
+					}
+					fmt.Fprintf(&sb, "    %s%s  %s\n", ln, colon, content)
+				}
+			}
+		}
+	}
+	return sb.String()
+}
+
+func (r ScanResult) jsonByCommand(c *Config) (string, error) {
+	b, err := json.MarshalIndent(r.byCommand(c), "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+func (r ScanResult) yamlByCommand(c *Config) (string, error) {
+	b, err := yaml.Marshal(r.byCommand(c))
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
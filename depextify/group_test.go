@@ -0,0 +1,68 @@
+package depextify
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResult_ByCommand(t *testing.T) {
+	res := ScanResult{
+		"a.sh": {
+			"jq":   {{Line: 1, Col: 1, Len: 2, FullLine: "jq . x"}},
+			"curl": {{Line: 2, Col: 1, Len: 4, FullLine: "curl x"}, {Line: 12, Col: 3, Len: 4, FullLine: "  curl y"}},
+		},
+		"b.sh": {
+			"jq": {{Line: 5, Col: 1, Len: 2, FullLine: "jq . y"}},
+		},
+		"c.sh": {
+			"jq":  {{Line: 1, Col: 1, Len: 2, FullLine: "jq . z"}, {Line: 2, Col: 1, Len: 2, FullLine: "jq . w"}},
+			"aws": {{Line: 3, Col: 1, Len: 3, FullLine: "aws s3 ls"}},
+		},
+	}
+
+	t.Run("sort by count", func(t *testing.T) {
+		usages := res.ByCommand(SortByCount)
+		require.Len(t, usages, 3)
+		require.Equal(t, "jq", usages[0].Command)
+		require.Equal(t, 4, usages[0].Count)
+		require.Equal(t, []string{"c.sh", "a.sh", "b.sh"}, []string{usages[0].Files[0].Path, usages[0].Files[1].Path, usages[0].Files[2].Path})
+		require.Equal(t, 2, usages[0].Files[0].Count)
+		require.Equal(t, "curl", usages[1].Command)
+		require.Equal(t, "aws", usages[2].Command)
+	})
+
+	t.Run("sort by name", func(t *testing.T) {
+		usages := res.ByCommand(SortByName)
+		require.Equal(t, []string{"aws", "curl", "jq"}, []string{usages[0].Command, usages[1].Command, usages[2].Command})
+		require.Equal(t, "a.sh", usages[2].Files[0].Path)
+	})
+
+	t.Run("text", func(t *testing.T) {
+		cfg := &Config{GroupBy: GroupByCommand, SortBy: SortByCount}
+		require.Equal(t, "jq: 4 in 3 files\n  c.sh\n  a.sh\n  b.sh\ncurl: 2 in 1 file\n  a.sh\naws: 1 in 1 file\n  c.sh\n", res.Format(cfg))
+
+		cfg = &Config{GroupBy: GroupByCommand, SortBy: SortByName, ShowCount: true, ShowPos: true}
+		small := ScanResult{"a.sh": {"curl": res["a.sh"]["curl"]}, "c.sh": {"aws": res["c.sh"]["aws"]}}
+		require.Equal(t, "aws: 1 in 1 file\n  c.sh: 1\n     3:  aws s3 ls\ncurl: 2 in 1 file\n  a.sh: 2\n     2:  curl x\n    12:  curl y\n", small.Format(cfg))
+	})
+
+	t.Run("JSON and YAML", func(t *testing.T) {
+		cfg := &Config{GroupBy: GroupByCommand, SortBy: SortByName}
+		small := ScanResult{"c.sh": {"aws": res["c.sh"]["aws"]}}
+
+		out, err := small.JSON(cfg)
+		require.NoError(t, err)
+		require.JSONEq(t, `[{"Command":"aws","Count":1,"Files":[{"Path":"c.sh","Count":1}]}]`, out)
+
+		cfg.ShowPos = true
+		out, err = small.JSON(cfg)
+		require.NoError(t, err)
+		require.JSONEq(t, `[{"Command":"aws","Count":1,"Files":[{"Path":"c.sh","Count":1,"Occurrences":[{"Line":3,"Col":1,"Len":3,"FullLine":"aws s3 ls"}]}]}]`, out)
+
+		cfg.ShowPos = false
+		out, err = small.YAML(cfg)
+		require.NoError(t, err)
+		require.Equal(t, "- command: aws\n  count: 1\n  files:\n    - path: c.sh\n      count: 1\n", out)
+	})
+}
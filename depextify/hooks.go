@@ -0,0 +1,183 @@
+package depextify
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+type (
+	// PreCommitExtractor extracts commands from `language: system` hooks of
+	// pre-commit configs.
+	PreCommitExtractor struct{}
+
+	// LefthookExtractor extracts commands from lefthook configs.
+	LefthookExtractor struct{}
+
+	// GitHookExtractor extracts commands from git hook scripts, tagging them
+	// with the hook they run in.
+	GitHookExtractor struct {
+		Hook string
+	}
+)
+
+var (
+	rePreCommit = regexp.MustCompile(`^\.pre-commit-(config|hooks)\.ya?ml$`)
+	reLefthook  = regexp.MustCompile(`^\.?lefthook(-local)?\.ya?ml$`)
+	reGitHook   = regexp.MustCompile(`(^|/)\.(githooks|husky)/([^/_.][^/]*)$`)
+
+	// pre-commit languages whose entry is a command on the developer's machine
+	preCommitSystemLanguages = map[string]bool{"system": true, "unsupported": true}
+)
+
+// mappingValue returns the value of key in a YAML mapping node.
+func mappingValue(n *yaml.Node, key string) *yaml.Node {
+	if n.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			return n.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// addYAMLScript analyzes a YAML scalar as shell code and records its commands tagged with ctx.
+func addYAMLScript(val *yaml.Node, ctx string, lines [][]byte, results map[string][]posInfo) {
+	cPositions, err := analyzeShellCode(val.Value)
+	if err != nil {
+		return
+	}
+	tagged := make(map[string][]posInfo)
+	applyYAMLOffset(cPositions, val, lines, tagged)
+	for cmd, infos := range tagged {
+		for _, info := range infos {
+			info.ctx = ctx
+			results[cmd] = append(results[cmd], info)
+		}
+	}
+}
+
+func (e *PreCommitExtractor) Extract(content []byte) (map[string][]posInfo, error) {
+	var node yaml.Node
+	if err := yaml.NewDecoder(bytes.NewReader(content)).Decode(&node); err != nil {
+		return nil, err
+	}
+
+	lines := bytes.Split(content, []byte("\n"))
+	results := make(map[string][]posInfo)
+
+	var walk func(*yaml.Node)
+	walk = func(n *yaml.Node) {
+		switch n.Kind {
+		case yaml.MappingNode:
+			id, entry, language := mappingValue(n, "id"), mappingValue(n, "entry"), mappingValue(n, "language")
+			if id != nil && entry != nil && entry.Kind == yaml.ScalarNode && language != nil && preCommitSystemLanguages[language.Value] {
+				ctx := "hook " + id.Value
+				if stages := mappingValue(n, "stages"); stages != nil && stages.Kind == yaml.SequenceNode {
+					names := make([]string, 0, len(stages.Content))
+					for _, s := range stages.Content {
+						names = append(names, s.Value)
+					}
+					ctx += " (" + strings.Join(names, ", ") + ")"
+				}
+				addYAMLScript(entry, ctx, lines, results)
+				return
+			}
+			for i := 1; i < len(n.Content); i += 2 {
+				walk(n.Content[i])
+			}
+		case yaml.SequenceNode, yaml.DocumentNode:
+			for _, child := range n.Content {
+				walk(child)
+			}
+		}
+	}
+
+	walk(&node)
+	return results, nil
+}
+
+func (e *LefthookExtractor) Extract(content []byte) (map[string][]posInfo, error) {
+	var node yaml.Node
+	if err := yaml.NewDecoder(bytes.NewReader(content)).Decode(&node); err != nil {
+		return nil, err
+	}
+
+	lines := bytes.Split(content, []byte("\n"))
+	results := make(map[string][]posInfo)
+	if len(node.Content) == 0 || node.Content[0].Kind != yaml.MappingNode {
+		return results, nil
+	}
+
+	// addJob records the run: or runner: of a command, script or job.
+	addJob := func(job *yaml.Node, ctx string) {
+		if run := mappingValue(job, "run"); run != nil && run.Kind == yaml.ScalarNode {
+			addYAMLScript(run, ctx, lines, results)
+		}
+		if runner := mappingValue(job, "runner"); runner != nil && runner.Kind == yaml.ScalarNode {
+			addYAMLScript(runner, ctx, lines, results)
+		}
+	}
+
+	root := node.Content[0]
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		hook, body := root.Content[i].Value, root.Content[i+1]
+
+		for _, kind := range []string{"commands", "scripts"} {
+			jobs := mappingValue(body, kind)
+			if jobs == nil || jobs.Kind != yaml.MappingNode {
+				continue
+			}
+			for j := 0; j+1 < len(jobs.Content); j += 2 {
+				addJob(jobs.Content[j+1], hook+"/"+jobs.Content[j].Value)
+			}
+		}
+
+		if jobs := mappingValue(body, "jobs"); jobs != nil && jobs.Kind == yaml.SequenceNode {
+			for j, job := range jobs.Content {
+				name := fmt.Sprint(j + 1)
+				if n := mappingValue(job, "name"); n != nil {
+					name = n.Value
+				}
+				addJob(job, hook+"/"+name)
+			}
+		}
+	}
+
+	return results, nil
+}
+
+func (e *GitHookExtractor) Extract(content []byte) (map[string][]posInfo, error) {
+	cmds, err := analyzeShellCode(string(content))
+	if err != nil {
+		return nil, err
+	}
+	for cmd, infos := range cmds {
+		for i := range infos {
+			infos[i].ctx = "hook " + e.Hook
+		}
+		cmds[cmd] = infos
+	}
+	return cmds, nil
+}
+
+// hookEntry reports whether the hidden file or directory name holds hooks,
+// so that scans reach it without ShowHidden.
+func hookEntry(name string) bool {
+	return name == ".githooks" || name == ".husky" || rePreCommit.MatchString(name) || reLefthook.MatchString(name)
+}
+
+// gitHookName returns the hook a script under .githooks/ or .husky/ implements.
+func gitHookName(path string) (string, bool) {
+	m := reGitHook.FindStringSubmatch(filepath.ToSlash(path))
+	if m == nil {
+		return "", false
+	}
+	return m[3], true
+}
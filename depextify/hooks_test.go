@@ -0,0 +1,101 @@
+package depextify
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtractPreCommit(t *testing.T) {
+	content := `repos:
+  - repo: https://github.com/pre-commit/pre-commit-hooks
+    rev: v4.5.0
+    hooks:
+      - id: trailing-whitespace
+  - repo: local
+    hooks:
+      - id: shellcheck
+        name: shellcheck
+        entry: shellcheck --severity=warning
+        language: system
+        types: [shell]
+      - id: tests
+        name: tests
+        entry: go test ./...
+        language: system
+        stages: [pre-push, manual]
+      - id: prettier
+        entry: prettier --write
+        language: node
+`
+	res, err := (&PreCommitExtractor{}).Extract([]byte(content))
+	require.NoError(t, err)
+	require.Equal(t, map[string][]posInfo{
+		"shellcheck": {{line: 10, col: 16, len: 10, ctx: "hook shellcheck"}},
+		"go":         {{line: 15, col: 16, len: 2, ctx: "hook tests (pre-push, manual)"}},
+	}, res)
+}
+
+func TestExtractLefthook(t *testing.T) {
+	content := `pre-commit:
+  parallel: true
+  commands:
+    lint:
+      glob: "*.{js,ts}"
+      run: npx eslint {staged_files}
+  scripts:
+    "check.rb":
+      runner: ruby
+pre-push:
+  jobs:
+    - name: audit
+      run: |
+        cargo audit
+    - run: trivy fs .
+`
+	res, err := (&LefthookExtractor{}).Extract([]byte(content))
+	require.NoError(t, err)
+	require.Equal(t, map[string][]posInfo{
+		"npx":   {{line: 6, col: 12, len: 3, ctx: "pre-commit/lint"}},
+		"ruby":  {{line: 9, col: 15, len: 4, ctx: "pre-commit/check.rb"}},
+		"cargo": {{line: 14, col: 9, len: 5, ctx: "pre-push/audit"}},
+		"trivy": {{line: 15, col: 12, len: 5, ctx: "pre-push/2"}},
+	}, res)
+}
+
+func TestExtractGitHook(t *testing.T) {
+	ext := GetExtractor(".husky/pre-commit")
+	require.Equal(t, &GitHookExtractor{Hook: "pre-commit"}, ext)
+
+	res, err := ext.Extract([]byte("npx lint-staged\n"))
+	require.NoError(t, err)
+	require.Equal(t, map[string][]posInfo{
+		"npx": {{line: 1, col: 1, len: 3, ctx: "hook pre-commit"}},
+	}, res)
+
+	require.Nil(t, GetExtractor(".husky/_/husky.sh"))
+	require.Equal(t, &GitHookExtractor{Hook: "commit-msg"}, GetExtractor("repo/.githooks/commit-msg"))
+	require.IsType(t, &MarkdownExtractor{}, GetExtractor(".githooks/README.md"))
+}
+
+func TestScanFS_Hooks(t *testing.T) {
+	fsys := fstest.MapFS{
+		"repo/.husky/pre-commit":       {Data: []byte("npx lint-staged\n")},
+		"repo/.githooks/pre-push":      {Data: []byte("cargo test\n")},
+		"repo/.pre-commit-config.yaml": {Data: []byte("repos:\n  - repo: local\n    hooks:\n      - id: lint\n        entry: golangci-lint run\n        language: system\n")},
+		"repo/.lefthook.yml":           {Data: []byte("pre-commit:\n  commands:\n    fmt:\n      run: gofmt -l .\n")},
+		"repo/.hidden/x.sh":            {Data: []byte("shellcheck x.sh\n")},
+		"repo/.husky/.hidden/pre-push": {Data: []byte("wget example.com\n")},
+	}
+
+	// Hooks are found without ShowHidden
+	res, err := (&Config{}).ScanFS(fsys, "repo")
+	require.NoError(t, err)
+	require.Contains(t, res["repo/.husky/pre-commit"], "npx")
+	require.Contains(t, res["repo/.githooks/pre-push"], "cargo")
+	require.Contains(t, res["repo/.pre-commit-config.yaml"], "golangci-lint")
+	require.Contains(t, res["repo/.lefthook.yml"], "gofmt")
+	require.NotContains(t, res, "repo/.hidden/x.sh")
+	require.NotContains(t, res, "repo/.husky/.hidden/pre-push")
+}
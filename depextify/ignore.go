@@ -0,0 +1,340 @@
+package depextify
+
+import (
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	ignoreFileName    = ".depextifyignore"
+	gitignoreFileName = ".gitignore"
+)
+
+type (
+	// ignorePattern is a line of an ignore file, in the syntax of gitignore.
+	ignorePattern struct {
+		// segments are matched against the path components; "**" matches any
+		// number of them.
+		segments []string
+		negate   bool
+		dirOnly  bool
+	}
+
+	// ignoreList holds the patterns of an ignore file, which are relative to
+	// the directory dir (slash-separated, relative to the top directory).
+	ignoreList struct {
+		dir      string
+		patterns []ignorePattern
+		// git is set for the patterns of .gitignore and .git/info/exclude,
+		// which do not apply inside archives.
+		git bool
+	}
+
+	// ignoreMatcher decides which paths are excluded from a scan, the way git
+	// does: the ignore files of deeper directories take precedence, and the
+	// last matching pattern of a file wins.
+	ignoreMatcher struct {
+		fsys fs.FS
+		// root is the directory paths are given relative to, and rootRel
+		// where it is relative to the top directory.
+		root    string
+		rootRel string
+		// lists are in increasing precedence.
+		lists []ignoreList
+		// excludes holds the patterns of Config.Excludes, which take
+		// precedence over every ignore file.
+		excludes  []ignoreList
+		gitignore bool
+	}
+)
+
+// parseIgnore parses the content of an ignore file.
+func parseIgnore(lines []string) []ignorePattern {
+	var patterns []ignorePattern
+	for _, line := range lines {
+		line = strings.TrimSuffix(line, "\r")
+		// Trailing spaces are ignored unless escaped
+		for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, `\ `) {
+			line = line[:len(line)-1]
+		}
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		var p ignorePattern
+		if line[0] == '!' {
+			p.negate = true
+			line = line[1:]
+		} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			p.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+
+		// A pattern without a slash matches at any level below the ignore
+		// file; otherwise it is anchored to its directory.
+		if !strings.Contains(line, "/") {
+			p.segments = []string{"**", line}
+		} else {
+			p.segments = strings.Split(strings.TrimPrefix(line, "/"), "/")
+		}
+		for i, seg := range p.segments {
+			p.segments[i] = globSegment(seg)
+		}
+		patterns = append(patterns, p)
+	}
+	return patterns
+}
+
+// globSegment translates a segment of a gitignore pattern to the syntax of
+// path.Match, which negates classes with "[^...]" rather than "[!...]".
+func globSegment(seg string) string {
+	var sb strings.Builder
+	inClass := false
+	for i := 0; i < len(seg); i++ {
+		switch ch := seg[i]; {
+		case ch == '\\' && i+1 < len(seg):
+			sb.WriteString(seg[i : i+2])
+			i++
+			continue
+		case ch == '[' && !inClass:
+			inClass = true
+			sb.WriteByte(ch)
+			if i+1 < len(seg) && seg[i+1] == '!' {
+				sb.WriteByte('^')
+				i++
+			}
+			continue
+		case ch == ']' && inClass:
+			inClass = false
+		}
+		sb.WriteByte(seg[i])
+	}
+	return sb.String()
+}
+
+func (p *ignorePattern) match(rel string, isDir bool) bool {
+	if p.dirOnly && !isDir {
+		return false
+	}
+	return matchSegments(p.segments, strings.Split(rel, "/"))
+}
+
+func matchSegments(pattern, name []string) bool {
+	if len(pattern) == 0 {
+		return len(name) == 0
+	}
+	if pattern[0] == "**" {
+		// A trailing "**" matches everything inside, but not the directory
+		if len(pattern) == 1 {
+			return len(name) > 0
+		}
+		for i := range len(name) + 1 {
+			if matchSegments(pattern[1:], name[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(name) == 0 {
+		return false
+	}
+	ok, _ := path.Match(pattern[0], name[0])
+	return ok && matchSegments(pattern[1:], name[1:])
+}
+
+// within returns rel relative to dir, if it is inside it.
+func within(dir, rel string) (string, bool) {
+	if dir == "." {
+		return rel, rel != "."
+	}
+	return strings.CutPrefix(rel, dir+"/")
+}
+
+// newIgnoreMatcher loads the ignore files from the top of the project (the
+// root of its git repository, or the current directory) down to dir, the
+// directory being scanned.
+func (c *Config) newIgnoreMatcher(fsys fs.FS, dir string) *ignoreMatcher {
+	m := &ignoreMatcher{fsys: fsys, root: dir, rootRel: ".", gitignore: !c.NoGitignore}
+
+	// The top directory and the directories down to dir, in a form that can
+	// be read from fsys
+	var top string
+	var dirs []string
+	if _, ok := fsys.(osFS); ok {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			abs = dir
+		}
+		top = findTop(abs)
+		if rel, err := filepath.Rel(top, abs); err == nil {
+			m.rootRel = filepath.ToSlash(rel)
+		}
+		for d := abs; ; d = filepath.Dir(d) {
+			dirs = append(dirs, d)
+			if d == top || d == filepath.Dir(d) {
+				break
+			}
+		}
+	} else {
+		// The whole filesystem is the project
+		top = "."
+		m.rootRel = dir
+		for d := dir; ; d = path.Dir(d) {
+			dirs = append(dirs, d)
+			if d == "." {
+				break
+			}
+		}
+	}
+
+	if m.gitignore {
+		m.load(joinPath(fsys, top, ".git", "info", "exclude"), ".", true)
+	}
+	for i := len(dirs) - 1; i >= 0; i-- {
+		rel := m.rootRel
+		for range i {
+			rel = path.Dir(rel)
+		}
+		m.loadDir(dirs[i], rel)
+	}
+
+	if len(c.Excludes) > 0 {
+		m.excludes = []ignoreList{{dir: m.rootRel, patterns: parseIgnore(c.Excludes)}}
+	}
+	return m
+}
+
+// findTop returns the root of the git repository dir is in, or else the
+// current directory if dir is inside it, or else dir itself.
+func findTop(dir string) string {
+	for d := dir; ; d = filepath.Dir(d) {
+		if _, err := os.Lstat(filepath.Join(d, ".git")); err == nil {
+			return d
+		}
+		if d == filepath.Dir(d) {
+			break
+		}
+	}
+	if wd, err := os.Getwd(); err == nil {
+		if rel, err := filepath.Rel(wd, dir); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return wd
+		}
+	}
+	return dir
+}
+
+// load adds the patterns of the ignore file name, relative to dir.
+func (m *ignoreMatcher) load(name, dir string, git bool) {
+	content, err := fs.ReadFile(m.fsys, name)
+	if err != nil {
+		return
+	}
+	if patterns := parseIgnore(strings.Split(string(content), "\n")); len(patterns) > 0 {
+		m.lists = append(m.lists, ignoreList{dir: dir, patterns: patterns, git: git})
+	}
+}
+
+// loadDir adds the ignore files of the directory p of fsys, at rel relative
+// to the top directory. .depextifyignore takes precedence over .gitignore.
+func (m *ignoreMatcher) loadDir(p, rel string) {
+	if m.gitignore {
+		m.load(joinPath(m.fsys, p, gitignoreFileName), rel, true)
+	}
+	m.load(joinPath(m.fsys, p, ignoreFileName), rel, false)
+}
+
+// enter returns the matcher for the files under the directory p, which also
+// applies its own ignore files. m is left unchanged.
+func (m *ignoreMatcher) enter(p string) *ignoreMatcher {
+	sub := *m
+	sub.lists = m.lists[:len(m.lists):len(m.lists)]
+	sub.loadDir(p, m.rel(p))
+	return &sub
+}
+
+// rel returns the path p of fsys relative to the top directory.
+func (m *ignoreMatcher) rel(p string) string {
+	var rel string
+	if _, ok := m.fsys.(osFS); ok {
+		r, err := filepath.Rel(m.root, p)
+		if err != nil {
+			return p
+		}
+		rel = filepath.ToSlash(r)
+	} else if r, ok := within(m.root, p); ok {
+		rel = r
+	} else {
+		rel = "."
+	}
+	return path.Join(m.rootRel, rel)
+}
+
+// matchRel reports whether rel, relative to the top directory, is excluded.
+// groups are in increasing precedence.
+func matchRel(rel string, isDir bool, groups ...[]ignoreList) bool {
+	for g := len(groups) - 1; g >= 0; g-- {
+		lists := groups[g]
+		for i := len(lists) - 1; i >= 0; i-- {
+			r, ok := within(lists[i].dir, rel)
+			if !ok {
+				continue
+			}
+			patterns := lists[i].patterns
+			for j := len(patterns) - 1; j >= 0; j-- {
+				if patterns[j].match(r, isDir) {
+					return !patterns[j].negate
+				}
+			}
+		}
+	}
+	return false
+}
+
+// excluded reports whether the path p of fsys is excluded. The directories it
+// is in must have been checked already.
+func (m *ignoreMatcher) excluded(p string, isDir bool) bool {
+	return matchRel(m.rel(p), isDir, m.lists, m.excludes)
+}
+
+// excludedTarget reports whether the target p of fsys, or a directory it is in
+// below the top directory, is excluded.
+func (m *ignoreMatcher) excludedTarget(p string, isDir bool) bool {
+	return excludedPath(m.rel(p), isDir, m.lists, m.excludes)
+}
+
+// excludedPath reports whether rel or one of its parent directories is
+// excluded.
+func excludedPath(rel string, isDir bool, groups ...[]ignoreList) bool {
+	if rel == "." {
+		return false
+	}
+	parts := strings.Split(rel, "/")
+	for i := range parts {
+		last := i == len(parts)-1
+		if matchRel(strings.Join(parts[:i+1], "/"), !last || isDir, groups...) {
+			return true
+		}
+	}
+	return false
+}
+
+// archiveLists returns the patterns that apply to the files of an archive:
+// those of .depextifyignore and Excludes, relative to the root of the archive.
+func (m *ignoreMatcher) archiveLists() []ignoreList {
+	var lists []ignoreList
+	for _, l := range append(m.lists[:len(m.lists):len(m.lists)], m.excludes...) {
+		if !l.git {
+			lists = append(lists, ignoreList{dir: ".", patterns: l.patterns})
+		}
+	}
+	return lists
+}
@@ -0,0 +1,170 @@
+package depextify
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIgnorePattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		isDir   bool
+		want    bool
+	}{
+		{"node_modules", "node_modules", true, true},
+		{"node_modules", "web/node_modules", true, true},
+		{"*.log", "a/b/debug.log", false, true},
+		{"build/", "build", true, true},
+		{"build/", "build", false, false},
+		{"build/", "src/build", true, true},
+		{"/dist", "dist", true, true},
+		{"/dist", "web/dist", true, false},
+		{"doc/*.sh", "doc/gen.sh", false, true},
+		{"doc/*.sh", "doc/sub/gen.sh", false, false},
+		{"doc/*.sh", "web/doc/gen.sh", false, false},
+		{"**/tmp", "a/b/tmp", true, true},
+		{"a/**/b", "a/b", true, true},
+		{"a/**/b", "a/x/y/b", true, true},
+		{"out/**", "out", true, false},
+		{"out/**", "out/x/y.sh", false, true},
+		{"test?.sh", "test1.sh", false, true},
+		{"[ab].sh", "c.sh", false, false},
+		{"[!ab].sh", "c.sh", false, true},
+		{"[!ab].sh", "a.sh", false, false},
+		{"gen/[!_]*.go", "gen/_skip.go", false, false},
+		{`\[!a].sh`, "[!a].sh", false, true},
+		{`\#hash.sh`, "#hash.sh", false, true},
+		{`\!bang.sh`, "!bang.sh", false, true},
+		{"trailing.sh   ", "trailing.sh", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.path, func(t *testing.T) {
+			patterns := parseIgnore([]string{tt.pattern})
+			require.Len(t, patterns, 1)
+			require.Equal(t, tt.want, patterns[0].match(tt.path, tt.isDir))
+		})
+	}
+
+	require.Empty(t, parseIgnore([]string{"", "# comment", "   ", "/"}))
+}
+
+func TestScan_Ignore(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		".git/info/exclude":           "local.sh\n",
+		".gitignore":                  "node_modules/\n/dist\n*.gen.sh\n!keep.gen.sh\n",
+		"deploy.sh":                   "rsync -a dist/ host:\n",
+		"local.sh":                    "ngrok http 80\n",
+		"node_modules/pkg/install.sh": "node-gyp rebuild\n",
+		"dist/bundle.sh":              "wget example.com\n",
+		"api.gen.sh":                  "protoc x\n",
+		"keep.gen.sh":                 "buf generate\n",
+		"web/.gitignore":              "dist\n!api.gen.sh\n",
+		"web/dist/x.sh":               "terser x.js\n",
+		"web/api.gen.sh":              "openapi-generator generate\n",
+		"web/scripts/lint.sh":         "eslint .\n",
+		"web/.depextifyignore":        "scripts/\n",
+		"tools/.depextifyignore":      "!*.gen.sh\n",
+		"tools/tool.gen.sh":           "stringer x\n",
+		"tools/dist/release.sh":       "goreleaser release\n",
+	})
+	p := func(name string) string { return filepath.Join(dir, filepath.FromSlash(name)) }
+
+	t.Run("gitignore", func(t *testing.T) {
+		res, err := (&Config{ShowHidden: true}).Scan(dir)
+		require.NoError(t, err)
+		require.Contains(t, res[p("deploy.sh")], "rsync")
+		require.Contains(t, res[p("keep.gen.sh")], "buf")
+		// A deeper ignore file takes precedence
+		require.Contains(t, res[p("web/api.gen.sh")], "openapi-generator")
+		require.Contains(t, res[p("tools/tool.gen.sh")], "stringer")
+		// /dist is anchored to the root
+		require.Contains(t, res[p("tools/dist/release.sh")], "goreleaser")
+
+		require.NotContains(t, res, p("local.sh"))
+		require.NotContains(t, res, p("node_modules/pkg/install.sh"))
+		require.NotContains(t, res, p("dist/bundle.sh"))
+		require.NotContains(t, res, p("api.gen.sh"))
+		require.NotContains(t, res, p("web/dist/x.sh"))
+		require.NotContains(t, res, p("web/scripts/lint.sh"))
+		for path := range res {
+			require.NotContains(t, path, ".git"+string(filepath.Separator))
+		}
+	})
+
+	t.Run("subdirectory", func(t *testing.T) {
+		// The ignore files of parent directories still apply
+		res, err := (&Config{}).Scan(p("web"))
+		require.NoError(t, err)
+		require.Contains(t, res, p("web/api.gen.sh"))
+		require.NotContains(t, res, p("web/dist/x.sh"))
+
+		res, err = (&Config{}).Scan(p("node_modules/pkg"))
+		require.NoError(t, err)
+		require.Empty(t, res)
+
+		res, err = (&Config{}).Scan(p("api.gen.sh"))
+		require.NoError(t, err)
+		require.Empty(t, res)
+	})
+
+	t.Run("no gitignore", func(t *testing.T) {
+		res, err := (&Config{NoGitignore: true}).Scan(dir)
+		require.NoError(t, err)
+		require.Contains(t, res, p("local.sh"))
+		require.Contains(t, res, p("node_modules/pkg/install.sh"))
+		require.Contains(t, res, p("api.gen.sh"))
+		// .depextifyignore is still applied
+		require.NotContains(t, res, p("web/scripts/lint.sh"))
+	})
+
+	t.Run("excludes", func(t *testing.T) {
+		res, err := (&Config{Excludes: []string{"/deploy.sh", "keep.gen.sh"}}).Scan(dir)
+		require.NoError(t, err)
+		require.NotContains(t, res, p("deploy.sh"))
+		require.NotContains(t, res, p("keep.gen.sh"))
+		require.Contains(t, res, p("web/api.gen.sh"))
+	})
+
+	t.Run("outside a repository", func(t *testing.T) {
+		require.NoError(t, os.RemoveAll(p(".git")))
+		res, err := (&Config{}).Scan(p("web"))
+		require.NoError(t, err)
+		require.Contains(t, res, p("web/api.gen.sh"))
+		require.NotContains(t, res, p("web/dist/x.sh"))
+		require.NotContains(t, res, p("web/scripts/lint.sh"))
+	})
+}
+
+func TestScanFS_Ignore(t *testing.T) {
+	fsys := fstest.MapFS{
+		".gitignore":               {Data: []byte("/vendor/\n")},
+		"app/.gitignore":           {Data: []byte("*.tmp.sh\n")},
+		"app/run.sh":               {Data: []byte("curl example.com\n")},
+		"app/scratch.tmp.sh":       {Data: []byte("wget example.com\n")},
+		"app/vendor/lib.sh":        {Data: []byte("jq . x\n")},
+		"vendor/lib.sh":            {Data: []byte("yq . x\n")},
+		"app/sub/x.tmp.sh":         {Data: []byte("rsync x y\n")},
+		"app/sub/.gitignore":       {Data: []byte("!x.tmp.sh\n")},
+		"app/sub/deep/y.sh":        {Data: []byte("make\n")},
+		"app/sub/.depextifyignore": {Data: []byte("deep\n")},
+	}
+
+	res, err := (&Config{}).ScanFS(fsys, "app")
+	require.NoError(t, err)
+	require.Contains(t, res, "app/run.sh")
+	require.Contains(t, res, "app/vendor/lib.sh")
+	require.Contains(t, res, "app/sub/x.tmp.sh")
+	require.NotContains(t, res, "app/scratch.tmp.sh")
+	require.NotContains(t, res, "app/sub/deep/y.sh")
+
+	res, err = (&Config{}).ScanFS(fsys, ".")
+	require.NoError(t, err)
+	require.NotContains(t, res, "vendor/lib.sh")
+	require.Contains(t, res, "app/run.sh")
+}
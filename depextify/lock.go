@@ -0,0 +1,175 @@
+package depextify
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultLockFile is the name of the manifest of declared commands.
+const DefaultLockFile = "depextify.lock.yaml"
+
+type (
+	// Lock is the manifest of the commands a repository intends to depend on.
+	Lock struct {
+		Commands []LockEntry `yaml:"commands"`
+	}
+
+	// LockEntry declares a command. Only Command is required; the other
+	// fields document it.
+	LockEntry struct {
+		Command    string `yaml:"command"`
+		MinVersion string `yaml:"min_version,omitempty" json:",omitempty"`
+		Reason     string `yaml:"reason,omitempty" json:",omitempty"`
+		Owner      string `yaml:"owner,omitempty" json:",omitempty"`
+	}
+
+	// Drift is how the commands found differ from a lock.
+	Drift struct {
+		// Undeclared are the commands found the lock does not declare.
+		Undeclared []UndeclaredCommand
+		// Unused are the commands the lock declares no file uses.
+		Unused []LockEntry
+	}
+
+	// UndeclaredCommand is a command found the lock does not declare.
+	UndeclaredCommand struct {
+		Command string
+		Files   []string
+	}
+)
+
+// ReadLock reads a lock file. Unknown fields, commands declared twice and
+// entries without a command are errors.
+func ReadLock(path string) (*Lock, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	var l Lock
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err := dec.Decode(&l); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	seen := make(map[string]bool, len(l.Commands))
+	for i, e := range l.Commands {
+		if e.Command == "" {
+			return nil, fmt.Errorf("%s: entry %d has no command", path, i+1)
+		}
+		if seen[e.Command] {
+			return nil, fmt.Errorf("%s: %q is declared twice", path, e.Command)
+		}
+		seen[e.Command] = true
+	}
+	return &l, nil
+}
+
+// Verify compares the commands of r with those l declares, both sorted by
+// command. r should hold every command, so that declared ones the report
+// hides count as used; undeclared ones are only those c reports.
+func (r ScanResult) Verify(c *Config, l *Lock) Drift {
+	declared := make(map[string]bool, len(l.Commands))
+	for _, e := range l.Commands {
+		declared[e.Command] = true
+	}
+
+	files := make(map[string][]string)
+	for path, cmds := range r {
+		for cmd := range cmds {
+			files[cmd] = append(files[cmd], path)
+		}
+	}
+
+	ignores := c.ignoreSet()
+	d := Drift{Undeclared: []UndeclaredCommand{}, Unused: []LockEntry{}}
+	for _, cmd := range slices.Sorted(maps.Keys(files)) {
+		if !declared[cmd] && !c.hides(cmd, ignores) {
+			slices.Sort(files[cmd])
+			d.Undeclared = append(d.Undeclared, UndeclaredCommand{Command: cmd, Files: files[cmd]})
+		}
+	}
+	for _, e := range l.Commands {
+		if _, ok := files[e.Command]; !ok {
+			d.Unused = append(d.Unused, e)
+		}
+	}
+	slices.SortFunc(d.Unused, func(a, b LockEntry) int { return strings.Compare(a.Command, b.Command) })
+	return d
+}
+
+// Empty reports whether the commands found are those declared.
+func (d Drift) Empty() bool {
+	return len(d.Undeclared) == 0 && len(d.Unused) == 0
+}
+
+// Format returns a line per undeclared command, with the files using it, and
+// per unused command, with its owner and reason.
+func (d Drift) Format(c *Config) string {
+	var sb strings.Builder
+
+	width := 0
+	for _, u := range d.Undeclared {
+		width = max(width, len(u.Command))
+	}
+	for _, e := range d.Unused {
+		width = max(width, len(e.Command))
+	}
+
+	line := func(status, cmd, detail string) {
+		cmd = fmt.Sprintf("%-*s", width, cmd)
+		status = fmt.Sprintf("%-10s", status)
+		if c.UseColor {
+			cmd = colorBold + cmd + colorReset
+			status = colorRed + status + colorReset
+		}
+		fmt.Fprintf(&sb, "%s  %s  %s\n", status, cmd, detail)
+	}
+
+	for _, u := range d.Undeclared {
+		line("undeclared", u.Command, "used in "+strings.Join(u.Files, ", "))
+	}
+	for _, e := range d.Unused {
+		var details []string
+		if e.Owner != "" {
+			details = append(details, "owner: "+e.Owner)
+		}
+		if e.Reason != "" {
+			details = append(details, "reason: "+e.Reason)
+		}
+		detail := "not used anymore"
+		if len(details) > 0 {
+			detail += " (" + strings.Join(details, ", ") + ")"
+		}
+		line("unused", e.Command, detail)
+	}
+	return sb.String()
+}
+
+// JSON returns the JSON encoding of the drift.
+func (d Drift) JSON() (string, error) {
+	b, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// YAML returns the YAML encoding of the drift.
+func (d Drift) YAML() (string, error) {
+	b, err := yaml.Marshal(d)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
@@ -0,0 +1,80 @@
+package depextify
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadLock(t *testing.T) {
+	dir := t.TempDir()
+	write := func(content string) string {
+		path := filepath.Join(dir, DefaultLockFile)
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+		return path
+	}
+
+	l, err := ReadLock(write(`commands:
+  - command: jq
+    min_version: "1.6"
+    reason: JSON in deploy scripts
+    owner: "@platform"
+  - command: terraform
+`))
+	require.NoError(t, err)
+	require.Equal(t, &Lock{Commands: []LockEntry{
+		{Command: "jq", MinVersion: "1.6", Reason: "JSON in deploy scripts", Owner: "@platform"},
+		{Command: "terraform"},
+	}}, l)
+
+	l, err = ReadLock(write(""))
+	require.NoError(t, err)
+	require.Empty(t, l.Commands)
+
+	for name, content := range map[string]string{
+		"unknown field": "commands:\n  - command: jq\n    version: 1\n",
+		"no command":    "commands:\n  - reason: none\n",
+		"duplicate":     "commands:\n  - command: jq\n  - command: jq\n",
+	} {
+		_, err := ReadLock(write(content))
+		require.Error(t, err, name)
+	}
+
+	_, err = ReadLock(filepath.Join(dir, "missing.yaml"))
+	require.Error(t, err)
+}
+
+func TestResult_Verify(t *testing.T) {
+	res := ScanResult{
+		"deploy.sh": {"jq": {{Line: 1}}, "aws": {{Line: 2}}},
+		"Makefile":  {"aws": {{Line: 1}}},
+	}
+	lock := &Lock{Commands: []LockEntry{
+		{Command: "terraform", Owner: "@platform", Reason: "infrastructure"},
+		{Command: "jq"},
+		{Command: "docker"},
+	}}
+
+	d := res.Verify(&Config{}, lock)
+	require.False(t, d.Empty())
+	require.Equal(t, Drift{
+		Undeclared: []UndeclaredCommand{{Command: "aws", Files: []string{"Makefile", "deploy.sh"}}},
+		Unused:     []LockEntry{{Command: "docker"}, {Command: "terraform", Owner: "@platform", Reason: "infrastructure"}},
+	}, d)
+	require.Equal(t, `undeclared  aws        used in Makefile, deploy.sh
+unused      docker     not used anymore
+unused      terraform  not used anymore (owner: @platform, reason: infrastructure)
+`, d.Format(&Config{}))
+
+	d = res.Verify(&Config{}, &Lock{Commands: []LockEntry{{Command: "aws"}, {Command: "jq"}}})
+	require.True(t, d.Empty())
+	require.Empty(t, d.Format(&Config{}))
+
+	// Commands the report hides need no declaration, but are used if declared
+	res["deploy.sh"]["curl"] = []Occurrence{{Line: 3}}
+	res["deploy.sh"]["cat"] = []Occurrence{{Line: 4}}
+	d = res.Verify(&Config{NoCoreutils: true, NoCommon: true, ExtraIgnores: []string{"aws"}}, &Lock{Commands: []LockEntry{{Command: "curl"}, {Command: "jq"}}})
+	require.True(t, d.Empty())
+}
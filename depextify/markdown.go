@@ -0,0 +1,163 @@
+package depextify
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+)
+
+// MarkdownExtractor extracts commands from shell code fences in Markdown files.
+type MarkdownExtractor struct{}
+
+var (
+	// Info strings whose fence body is a plain shell script.
+	markdownShellInfos = map[string]bool{
+		"sh": true, "bash": true, "zsh": true, "ksh": true, "shell": true,
+	}
+	// Info strings whose fence body is a terminal transcript with `$ ` prompts.
+	markdownSessionInfos = map[string]bool{
+		"console": true, "shell-session": true, "shellsession": true, "sh-session": true,
+	}
+)
+
+// parseFence reports whether line opens or closes a code fence and returns the
+// fence marker (e.g. "```" or "~~~~") and the info string following it.
+func parseFence(line string) (string, string, bool) {
+	trimmed := strings.TrimLeft(line, " \t")
+	if len(trimmed) < 3 || (trimmed[0] != '`' && trimmed[0] != '~') {
+		return "", "", false
+	}
+
+	n := 0
+	for n < len(trimmed) && trimmed[n] == trimmed[0] {
+		n++
+	}
+	if n < 3 {
+		return "", "", false
+	}
+
+	info := strings.TrimSpace(trimmed[n:])
+	// Backtick fences must not contain backticks in the info string.
+	if trimmed[0] == '`' && strings.Contains(info, "`") {
+		return "", "", false
+	}
+	return trimmed[:n], info, true
+}
+
+// fenceLanguage returns the language of a fence info string, e.g. "bash" for
+// "bash title=install.sh" or "{.bash}".
+func fenceLanguage(info string) string {
+	lang, _, _ := strings.Cut(info, " ")
+	lang = strings.Trim(lang, "{}")
+	lang = strings.TrimPrefix(lang, ".")
+	return strings.ToLower(lang)
+}
+
+// hasPrompt reports whether a block looks like a terminal transcript, as
+// READMEs often tag those `sh` rather than `console`.
+func hasPrompt(lines []string) bool {
+	for _, line := range lines {
+		if strings.HasPrefix(strings.TrimLeft(line, " \t"), "$ ") {
+			return true
+		}
+	}
+	return false
+}
+
+// sessionScript turns a terminal transcript into a shell script of the same
+// shape: prompts are blanked out, and output lines are emptied, so positions in
+// the script still match the transcript.
+func sessionScript(lines []string) string {
+	script := make([]string, len(lines))
+	continued := false
+
+	for i, line := range lines {
+		trimmed := strings.TrimLeft(line, " \t")
+		indent := len(line) - len(trimmed)
+
+		switch {
+		case continued:
+			script[i] = line
+		case trimmed == "$":
+			script[i] = ""
+		case strings.HasPrefix(trimmed, "$ "):
+			script[i] = line[:indent] + "  " + trimmed[2:]
+		default:
+			// Output line
+			script[i] = ""
+			continue
+		}
+
+		continued = strings.HasSuffix(strings.TrimRight(script[i], " \t"), "\\")
+	}
+
+	return strings.Join(script, "\n")
+}
+
+func (e *MarkdownExtractor) Extract(content []byte) (map[string][]posInfo, error) {
+	results := make(map[string][]posInfo)
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+
+	lineNum := 0
+	var (
+		fence     string
+		lang      string
+		startLine int
+		block     []string
+	)
+
+	flush := func() {
+		var script string
+		switch {
+		case markdownSessionInfos[lang], markdownShellInfos[lang] && hasPrompt(block):
+			script = sessionScript(block)
+		case markdownShellInfos[lang]:
+			script = strings.Join(block, "\n")
+		default:
+			return
+		}
+
+		cmds, err := analyzeShellCode(script)
+		if err != nil {
+			return
+		}
+		for cmd, infos := range cmds {
+			for _, info := range infos {
+				info.line += uint(startLine - 1)
+				results[cmd] = append(results[cmd], info)
+			}
+		}
+	}
+
+	for scanner.Scan() {
+		lineNum++
+		line := scanner.Text()
+
+		if fence == "" {
+			if marker, info, ok := parseFence(line); ok {
+				fence = marker
+				lang = fenceLanguage(info)
+				startLine = lineNum + 1
+				block = block[:0]
+			}
+			continue
+		}
+
+		// A closing fence uses the same character, is at least as long as the
+		// opening one and carries no info string.
+		if marker, info, ok := parseFence(line); ok && info == "" && marker[0] == fence[0] && len(marker) >= len(fence) {
+			flush()
+			fence = ""
+			continue
+		}
+
+		block = append(block, line)
+	}
+
+	// An unclosed fence runs to the end of the document.
+	if fence != "" {
+		flush()
+	}
+
+	return results, nil
+}
@@ -0,0 +1,68 @@
+package depextify
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtractMarkdown(t *testing.T) {
+	extractor := &MarkdownExtractor{}
+
+	t.Run("shell fences", func(t *testing.T) {
+		content := "# Install\n" +
+			"\n" +
+			"```sh\n" +
+			"brew install jq\n" +
+			"```\n" +
+			"\n" +
+			"~~~bash title=setup.sh\n" +
+			"  curl -sSL example.com | bash\n" +
+			"~~~\n"
+		res, err := extractor.Extract([]byte(content))
+		require.NoError(t, err)
+		require.Equal(t, []posInfo{{line: 4, col: 1, len: 4}}, res["brew"])
+		require.Equal(t, []posInfo{{line: 8, col: 3, len: 4, pipe: "bash"}}, res["curl"])
+		require.Contains(t, res, "bash")
+	})
+
+	t.Run("console prompts and output", func(t *testing.T) {
+		content := "```console\n" +
+			"$ terraform init \\\n" +
+			"    -upgrade\n" +
+			"Initializing the backend...\n" +
+			"$ kubectl get pods\n" +
+			"NAME  READY\n" +
+			"```\n"
+		res, err := extractor.Extract([]byte(content))
+		require.NoError(t, err)
+		require.Equal(t, []posInfo{{line: 2, col: 3, len: 9}}, res["terraform"])
+		require.Equal(t, []posInfo{{line: 5, col: 3, len: 7}}, res["kubectl"])
+		require.NotContains(t, res, "Initializing")
+		require.NotContains(t, res, "NAME")
+	})
+
+	t.Run("transcript tagged as shell", func(t *testing.T) {
+		content := "```sh\n" +
+			"$ depextify -count .\n" +
+			"jq: 1\n" +
+			"```\n"
+		res, err := extractor.Extract([]byte(content))
+		require.NoError(t, err)
+		require.Equal(t, map[string][]posInfo{"depextify": {{line: 2, col: 3, len: 9}}}, res)
+	})
+
+	t.Run("non-shell fences are ignored", func(t *testing.T) {
+		content := "```go\n" +
+			"fmt.Println()\n" +
+			"```\n" +
+			"````\n" +
+			"ls\n" +
+			"```sh\n" +
+			"````\n" +
+			"    make build\n"
+		res, err := extractor.Extract([]byte(content))
+		require.NoError(t, err)
+		require.Empty(t, res)
+	})
+}
@@ -0,0 +1,248 @@
+package depextify
+
+import (
+	"regexp"
+	"strings"
+)
+
+// NixExtractor extracts commands from shell snippets embedded in Nix
+// expressions: build phases, hooks, writeShellScript and runCommand bodies.
+type NixExtractor struct {
+	// Inputs also reports the packages listed in buildInputs,
+	// nativeBuildInputs and packages, tagged with the attribute name.
+	Inputs bool
+}
+
+var (
+	// Attributes whose value is a shell snippet: buildPhase = '' ... '';
+	reNixShellAttr = regexp.MustCompile(`\b(\w+Phase|(?:pre|post)[A-Z]\w*|shellHook|script)\s*=\s*''`)
+	// The text of writeShellApplication { ... } is a script; other texts
+	// are files, e.g. writeText or environment.etc.<name>.text.
+	reNixShellApp = regexp.MustCompile(`\bwriteShellApplication\s*\{`)
+	reNixTextAttr = regexp.MustCompile(`\btext\s*=\s*''`)
+	// Builders taking a name and a script: writeShellScriptBin "hello" '' ... ''
+	reNixScriptCall = regexp.MustCompile(`\b(writeShellScript(?:Bin)?|writeScript(?:Bin)?|write(?:Bash|Dash)(?:Bin)?)\s+(?:"[^"]*"|[\w.-]+)\s+''`)
+	// runCommand "name" { ... } '' ... ''
+	reNixRunCommand = regexp.MustCompile(`\b(runCommand(?:Local|CC|NoCC)?)\s+(?:"[^"]*"|[\w.-]+)\s+`)
+	reNixInputs     = regexp.MustCompile(`\b(nativeBuildInputs|buildInputs|propagatedBuildInputs|packages)\s*=\s*(?:with\s+[\w.]+\s*;\s*)?\[`)
+	reNixInputItem  = regexp.MustCompile(`^[A-Za-z_][\w'.-]*`)
+)
+
+// skipNixString returns the index just past the string starting at src[i],
+// which is either "..." or ''...''.
+func skipNixString(src string, i int) int {
+	if src[i] == '"' {
+		for i++; i < len(src); i++ {
+			switch {
+			case src[i] == '\\':
+				i++
+			case src[i] == '"':
+				return i + 1
+			case strings.HasPrefix(src[i:], "${"):
+				i = skipNixAntiquote(src, i) - 1
+			}
+		}
+		return len(src)
+	}
+
+	for i += 2; i < len(src); i++ {
+		switch {
+		case strings.HasPrefix(src[i:], "'''"), strings.HasPrefix(src[i:], "''$"), strings.HasPrefix(src[i:], "''\\"):
+			// Escapes
+			i += 2
+		case strings.HasPrefix(src[i:], "''"):
+			return i + 2
+		case strings.HasPrefix(src[i:], "${"):
+			i = skipNixAntiquote(src, i) - 1
+		}
+	}
+	return len(src)
+}
+
+// skipNixAntiquote returns the index just past the ${...} starting at src[i].
+func skipNixAntiquote(src string, i int) int {
+	depth := 0
+	for i += 2; i < len(src); i++ {
+		switch {
+		case src[i] == '"', strings.HasPrefix(src[i:], "''"):
+			i = skipNixString(src, i) - 1
+		case src[i] == '{':
+			depth++
+		case src[i] == '}':
+			if depth == 0 {
+				return i + 1
+			}
+			depth--
+		}
+	}
+	return len(src)
+}
+
+// nixIndentedString returns the shell code of the indented string whose
+// content starts at src[start], with Nix escapes and antiquotations replaced
+// by text of the same shape so positions match the Nix file.
+func nixIndentedString(src string, start int) string {
+	var sb strings.Builder
+	for i := start; i < len(src); i++ {
+		switch {
+		case strings.HasPrefix(src[i:], "'''"):
+			// '' is a literal ''
+			sb.WriteString(" ''")
+			i += 2
+		case strings.HasPrefix(src[i:], "''$"):
+			// ''$ is a literal $
+			sb.WriteString("  $")
+			i += 2
+		case strings.HasPrefix(src[i:], "''\\"):
+			// ''\x is an escaped x
+			sb.WriteString("   ")
+			i += 2
+		case strings.HasPrefix(src[i:], "''"):
+			return sb.String()
+		case strings.HasPrefix(src[i:], "${"):
+			// The value of an antiquotation is unknown here, so stand in a
+			// shell variable of the same length for it.
+			end := skipNixAntiquote(src, i)
+			sb.WriteString("$")
+			for _, r := range src[i+1 : end] {
+				if r == '\n' {
+					sb.WriteRune('\n')
+				} else {
+					sb.WriteString(strings.Repeat("x", len(string(r))))
+				}
+			}
+			i = end - 1
+		default:
+			sb.WriteByte(src[i])
+		}
+	}
+	return sb.String()
+}
+
+// skipNixSpace returns the index of the first non-space, non-comment byte at or after i.
+func skipNixSpace(src string, i int) int {
+	for i < len(src) {
+		switch {
+		case src[i] == ' ' || src[i] == '\t' || src[i] == '\n' || src[i] == '\r':
+			i++
+		case src[i] == '#':
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		default:
+			return i
+		}
+	}
+	return i
+}
+
+// skipNixAttrs returns the index after the attribute set starting at
+// src[i], an opening brace.
+func skipNixAttrs(src string, i int) int {
+	depth := 0
+	for ; i < len(src); i++ {
+		if src[i] == '"' || strings.HasPrefix(src[i:], "''") {
+			i = skipNixString(src, i) - 1
+		} else if src[i] == '{' {
+			depth++
+		} else if src[i] == '}' {
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		}
+	}
+	return i
+}
+
+// addNixScript records the commands of the indented string whose content
+// starts at src[start].
+func addNixScript(src string, start int, ctx string, idx lineIndex, results map[string][]posInfo) {
+	cmds := make(map[string][]posInfo)
+	addLiteralScript(nixIndentedString(src, start), start, idx, cmds)
+	for cmd, infos := range cmds {
+		for _, info := range infos {
+			info.ctx = ctx
+			results[cmd] = append(results[cmd], info)
+		}
+	}
+}
+
+// addNixInputs records the packages of the list whose "[" is at src[i].
+func addNixInputs(src string, i int, attr string, idx lineIndex, results map[string][]posInfo) {
+	for i = skipNixSpace(src, i+1); i < len(src) && src[i] != ']'; i = skipNixSpace(src, i) {
+		switch src[i] {
+		case '(':
+			// Arbitrary expression, e.g. (python3.withPackages (ps: [ ps.requests ]))
+			depth := 0
+			for ; i < len(src); i++ {
+				if src[i] == '(' {
+					depth++
+				} else if src[i] == ')' {
+					depth--
+					if depth == 0 {
+						i++
+						break
+					}
+				}
+			}
+			continue
+		case '"', '\'':
+			i = skipNixString(src, i)
+			continue
+		}
+
+		item := reNixInputItem.FindString(src[i:])
+		if item == "" {
+			i++
+			continue
+		}
+		name := strings.TrimPrefix(item, "pkgs.")
+		line, col := idx.position(i + len(item) - len(name))
+		results[name] = append(results[name], posInfo{line: line, col: col, len: uint(len(name)), ctx: attr})
+		i += len(item)
+	}
+}
+
+func (e *NixExtractor) Extract(content []byte) (map[string][]posInfo, error) {
+	src := string(content)
+	idx := newLineIndex(content)
+	results := make(map[string][]posInfo)
+
+	for _, m := range reNixShellAttr.FindAllStringSubmatchIndex(src, -1) {
+		addNixScript(src, m[1], src[m[2]:m[3]], idx, results)
+	}
+	for _, m := range reNixShellApp.FindAllStringIndex(src, -1) {
+		start := m[1] - 1
+		attrs := src[:skipNixAttrs(src, start)]
+		for _, t := range reNixTextAttr.FindAllStringIndex(attrs[start:], -1) {
+			addNixScript(src, start+t[1], "writeShellApplication", idx, results)
+		}
+	}
+	for _, m := range reNixScriptCall.FindAllStringSubmatchIndex(src, -1) {
+		addNixScript(src, m[1], src[m[2]:m[3]], idx, results)
+	}
+	for _, m := range reNixRunCommand.FindAllStringSubmatchIndex(src, -1) {
+		// Skip the environment argument, usually an attribute set
+		i := m[1]
+		if i < len(src) && src[i] == '{' {
+			i = skipNixAttrs(src, i)
+		} else {
+			for i < len(src) && src[i] != ' ' && src[i] != '\t' && src[i] != '\n' {
+				i++
+			}
+		}
+		i = skipNixSpace(src, i)
+		if strings.HasPrefix(src[i:], "''") {
+			addNixScript(src, i+2, src[m[2]:m[3]], idx, results)
+		}
+	}
+
+	if e.Inputs {
+		for _, m := range reNixInputs.FindAllStringSubmatchIndex(src, -1) {
+			addNixInputs(src, m[1]-1, src[m[2]:m[3]], idx, results)
+		}
+	}
+
+	return results, nil
+}
@@ -0,0 +1,77 @@
+package depextify
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtractNix(t *testing.T) {
+	content := `{ pkgs, ... }:
+pkgs.stdenv.mkDerivation {
+  nativeBuildInputs = with pkgs; [ pkg-config protobuf ];
+  buildInputs = [ pkgs.openssl (pkgs.python3.withPackages (ps: [ ps.requests ])) ];
+  buildPhase = ''
+    protoc --go_out=. api.proto
+    ${pkgs.jq}/bin/jq . config.json > out.json
+    echo ''${HOME} '''quoted'''
+  '';
+  postInstall = ''wrapProgram $out/bin/app --prefix PATH : ${lib.makeBinPath [ pkgs.git ]}'';
+  passthru.hello = pkgs.writeShellScriptBin "hello" ''
+    figlet "hello"
+  '';
+  passthru.report = pkgs.runCommand "report" { buildInputs = [ pkgs.pandoc ]; } ''
+    pandoc ${./README.md} -o $out
+  '';
+  passthru.deploy = pkgs.writeShellApplication {
+    name = "deploy";
+    runtimeInputs = [ pkgs.awscli2 ];
+    text = ''
+      aws s3 sync ./public s3://site
+    '';
+  };
+  passthru.notes = pkgs.writeTextFile { name = "notes"; text = ''
+    Install it, then restart nginx.
+  ''; };
+  environment.etc."app.conf".text = ''
+    listen 8080
+  '';
+}
+`
+	t.Run("scripts", func(t *testing.T) {
+		res, err := (&NixExtractor{}).Extract([]byte(content))
+		require.NoError(t, err)
+
+		require.Equal(t, []posInfo{{line: 6, col: 5, len: 6, ctx: "buildPhase"}}, res["protoc"])
+		require.Equal(t, []posInfo{{line: 10, col: 19, len: 11, ctx: "postInstall"}}, res["wrapProgram"])
+		require.Equal(t, []posInfo{{line: 12, col: 5, len: 6, ctx: "writeShellScriptBin"}}, res["figlet"])
+		require.Equal(t, []posInfo{{line: 15, col: 5, len: 6, ctx: "runCommand"}}, res["pandoc"])
+		require.Contains(t, res, "echo")
+		require.Equal(t, []posInfo{{line: 21, col: 7, len: 3, ctx: "writeShellApplication"}}, res["aws"])
+		// Other texts are files
+		require.NotContains(t, res, "Install")
+		require.NotContains(t, res, "listen")
+		// Antiquotations are not literal commands
+		require.NotContains(t, res, "/bin/jq")
+		require.NotContains(t, res, "pkg-config")
+	})
+
+	t.Run("inputs", func(t *testing.T) {
+		res, err := (&NixExtractor{Inputs: true}).Extract([]byte(content))
+		require.NoError(t, err)
+
+		require.Equal(t, []posInfo{{line: 3, col: 36, len: 10, ctx: "nativeBuildInputs"}}, res["pkg-config"])
+		require.Equal(t, []posInfo{{line: 4, col: 24, len: 7, ctx: "buildInputs"}}, res["openssl"])
+		require.Contains(t, res, "protobuf")
+		require.Equal(t, []posInfo{
+			{line: 15, col: 5, len: 6, ctx: "runCommand"},
+			{line: 14, col: 69, len: 6, ctx: "buildInputs"},
+		}, res["pandoc"])
+		require.NotContains(t, res, "python3.withPackages")
+	})
+}
+
+func TestNixIndentedString(t *testing.T) {
+	src := "x = ''\n  a ''${b} ${c {d}} '''\n'';"
+	require.Equal(t, "\n  a   ${b} $xxxxxxx  ''\n", nixIndentedString(src, 6))
+}
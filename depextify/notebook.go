@@ -0,0 +1,265 @@
+package depextify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// NotebookExtractor extracts commands from Jupyter notebooks: `!cmd` shell
+// escapes, `%%bash`-style cell magics, and shell fences in Markdown cells.
+type NotebookExtractor struct{}
+
+type notebookCell struct {
+	cellType string
+	lines    []string
+	// fileLines holds, for each cell line, the line in the notebook file where
+	// the source string it comes from is written.
+	fileLines []int
+}
+
+var (
+	// `!cmd`, `!!cmd`, `%sx cmd`, `%system cmd`, optionally assigned: `files = !ls`
+	reNotebookShellLine = regexp.MustCompile(`^(\s*(?:[A-Za-z_][A-Za-z0-9_]*\s*=\s*)?)(!!?|%sx\s|%system\s)`)
+	// `%%bash`, `%%sh`, `%%script bash`, ...
+	reNotebookShellCell = regexp.MustCompile(`^\s*%%(bash|sh|zsh|script\s+(ba|z|k)?sh)\b`)
+)
+
+// skipJSONValue consumes the next value from dec, whatever its shape.
+func skipJSONValue(dec *json.Decoder) error {
+	depth := 0
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		switch tok {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+		if depth == 0 {
+			return nil
+		}
+	}
+}
+
+// expectDelim consumes the next token and checks that it is the given delimiter.
+func expectDelim(dec *json.Decoder, d json.Delim) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok != d {
+		return fmt.Errorf("unexpected token %v, expected %v", tok, d)
+	}
+	return nil
+}
+
+// readCellSource reads a cell "source" value, which nbformat allows to be
+// either a single string or a list of strings, recording where each line is.
+func readCellSource(dec *json.Decoder, idx lineIndex, cell *notebookCell) error {
+	// open is set while the last cell line has not been terminated by "\n" yet.
+	open := false
+	appendPiece := func(s string, fileLine int) {
+		parts := strings.Split(s, "\n")
+		for i, part := range parts {
+			if i == 0 && open {
+				cell.lines[len(cell.lines)-1] += part
+				continue
+			}
+			if i == len(parts)-1 && part == "" {
+				break
+			}
+			cell.lines = append(cell.lines, part)
+			cell.fileLines = append(cell.fileLines, fileLine)
+		}
+		if s != "" {
+			open = !strings.HasSuffix(s, "\n")
+		}
+	}
+
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	switch v := tok.(type) {
+	case string:
+		appendPiece(v, idx.line(dec.InputOffset()))
+	case json.Delim:
+		if v != '[' {
+			return fmt.Errorf("unexpected cell source %v", v)
+		}
+		for dec.More() {
+			tok, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			s, ok := tok.(string)
+			if !ok {
+				return fmt.Errorf("unexpected cell source line %v", tok)
+			}
+			appendPiece(s, idx.line(dec.InputOffset()))
+		}
+		if _, err := dec.Token(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readNotebookCells decodes the cells of a notebook, keeping track of the
+// notebook file lines their sources are written on.
+func readNotebookCells(content []byte) ([]notebookCell, error) {
+	idx := newLineIndex(content)
+	dec := json.NewDecoder(bytes.NewReader(content))
+
+	if err := expectDelim(dec, '{'); err != nil {
+		return nil, err
+	}
+
+	var cells []notebookCell
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		if key != "cells" {
+			if err := skipJSONValue(dec); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if err := expectDelim(dec, '['); err != nil {
+			return nil, err
+		}
+		for dec.More() {
+			if err := expectDelim(dec, '{'); err != nil {
+				return nil, err
+			}
+			var cell notebookCell
+			for dec.More() {
+				field, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				switch field {
+				case "cell_type":
+					tok, err := dec.Token()
+					if err != nil {
+						return nil, err
+					}
+					cell.cellType, _ = tok.(string)
+				case "source":
+					if err := readCellSource(dec, idx, &cell); err != nil {
+						return nil, err
+					}
+				default:
+					if err := skipJSONValue(dec); err != nil {
+						return nil, err
+					}
+				}
+			}
+			if _, err := dec.Token(); err != nil {
+				return nil, err
+			}
+			cells = append(cells, cell)
+		}
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+	}
+
+	return cells, nil
+}
+
+// cellScript returns the shell code of a code cell, with non-shell lines
+// emptied and IPython prefixes blanked out so columns match the cell.
+func cellScript(lines []string) string {
+	script := make([]string, len(lines))
+
+	if len(lines) > 0 && reNotebookShellCell.MatchString(lines[0]) {
+		copy(script[1:], lines[1:])
+		return strings.Join(script, "\n")
+	}
+
+	for i, line := range lines {
+		if m := reNotebookShellLine.FindStringSubmatchIndex(line); m != nil {
+			script[i] = strings.Repeat(" ", m[1]) + line[m[1]:]
+		}
+	}
+	return strings.Join(script, "\n")
+}
+
+// analyzeCellLines analyzes each line of script on its own, so one IPython
+// line the shell parser rejects does not hide the others.
+func analyzeCellLines(script string) map[string][]posInfo {
+	results := make(map[string][]posInfo)
+	for i, line := range strings.Split(script, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		cmds, err := analyzeShellCode(line)
+		if err != nil {
+			continue
+		}
+		for cmd, infos := range cmds {
+			for _, info := range infos {
+				info.line += uint(i)
+				results[cmd] = append(results[cmd], info)
+			}
+		}
+	}
+	return results
+}
+
+func (e *NotebookExtractor) Extract(content []byte) (map[string][]posInfo, error) {
+	cells, err := readNotebookCells(content)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make(map[string][]posInfo)
+	for i, cell := range cells {
+		var cmds map[string][]posInfo
+
+		switch cell.cellType {
+		case "code":
+			script := cellScript(cell.lines)
+			if len(cell.lines) > 0 && reNotebookShellCell.MatchString(cell.lines[0]) {
+				cmds, err = analyzeShellCode(script)
+				if err != nil {
+					continue
+				}
+			} else {
+				cmds = analyzeCellLines(script)
+			}
+		case "markdown":
+			cmds, err = (&MarkdownExtractor{}).Extract([]byte(strings.Join(cell.lines, "\n")))
+			if err != nil {
+				continue
+			}
+		default:
+			continue
+		}
+
+		for cmd, infos := range cmds {
+			for _, info := range infos {
+				cellLine := int(info.line)
+				if cellLine < 1 || cellLine > len(cell.lines) {
+					continue
+				}
+				info.line = uint(cell.fileLines[cellLine-1])
+				info.text = cell.lines[cellLine-1]
+				info.ctx = fmt.Sprintf("cell %d:%d", i+1, cellLine)
+				results[cmd] = append(results[cmd], info)
+			}
+		}
+	}
+
+	return results, nil
+}
@@ -0,0 +1,62 @@
+package depextify
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtractNotebook(t *testing.T) {
+	content := `{
+ "cells": [
+  {
+   "cell_type": "code",
+   "execution_count": 1,
+   "metadata": {},
+   "outputs": [],
+   "source": [
+    "import os\n",
+    "!pip install pandas\n",
+    "files = !aws s3 ls s3://bucket"
+   ]
+  },
+  {
+   "cell_type": "code",
+   "metadata": {},
+   "source": [
+    "%%bash\n",
+    "set -e\n",
+    "gsutil cp a b\n"
+   ]
+  },
+  {
+   "cell_type": "markdown",
+   "metadata": {},
+   "source": "Run:\n` + "```sh" + `\nterraform apply\n` + "```" + `\n"
+  },
+  {
+   "cell_type": "raw",
+   "source": ["!ignored"]
+  }
+ ],
+ "metadata": {"kernelspec": {"name": "python3"}},
+ "nbformat": 4,
+ "nbformat_minor": 5
+}`
+
+	extractor := &NotebookExtractor{}
+	res, err := extractor.Extract([]byte(content))
+	require.NoError(t, err)
+
+	require.Equal(t, []posInfo{{line: 10, col: 2, len: 3, text: "!pip install pandas", ctx: "cell 1:2"}}, res["pip"])
+	require.Equal(t, []posInfo{{line: 11, col: 10, len: 3, text: "files = !aws s3 ls s3://bucket", ctx: "cell 1:3"}}, res["aws"])
+	require.Equal(t, []posInfo{{line: 20, col: 1, len: 6, text: "gsutil cp a b", ctx: "cell 2:3"}}, res["gsutil"])
+	require.Equal(t, []posInfo{{line: 26, col: 1, len: 9, text: "terraform apply", ctx: "cell 3:3"}}, res["terraform"])
+	require.NotContains(t, res, "import")
+	require.NotContains(t, res, "ignored")
+
+	t.Run("invalid JSON", func(t *testing.T) {
+		_, err := extractor.Extract([]byte(`{"cells": [`))
+		require.Error(t, err)
+	})
+}
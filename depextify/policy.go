@@ -0,0 +1,332 @@
+package depextify
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+type (
+	// PolicyRule restricts the commands of the files it applies to.
+	PolicyRule struct {
+		// Paths are the files the rule applies to, as patterns in the syntax
+		// of .gitignore; all files if empty.
+		Paths []string `yaml:"paths"`
+		// Types are the extractors of the files the rule applies to, e.g.
+		// "shell" or "dockerfile"; all files if empty.
+		Types []string `yaml:"types"`
+		// Allow, if set, lists the only commands allowed besides shell
+		// built-ins.
+		Allow []string `yaml:"allow"`
+		// Deny lists commands that must not be used.
+		Deny []string `yaml:"deny"`
+		// DenyPipeToShell forbids piping a downloader such as curl into a
+		// shell, as in "curl -fsSL url | sh".
+		DenyPipeToShell bool `yaml:"deny_pipe_to_shell"`
+		// DenyLines are regular expressions the lines commands are used on
+		// must not match, for what the other fields cannot express.
+		DenyLines []string `yaml:"deny_lines"`
+		// Message explains the rule in its violations.
+		Message string `yaml:"message"`
+	}
+
+	// Violation is a use of a command a policy rule forbids.
+	Violation struct {
+		Path     string
+		Command  string
+		Line     int
+		Col      int
+		FullLine string
+		Context  string `json:",omitempty" yaml:",omitempty"`
+		// Rule is the index of the violated rule in Config.Policy, or -1
+		// for Config.Denies.
+		Rule    int
+		Message string
+	}
+
+	// Violations are the violations of a policy, sorted by position.
+	Violations []Violation
+
+	// policyRule is a PolicyRule ready to be evaluated.
+	policyRule struct {
+		index int
+		// paths match relative to the absolute directory dir.
+		dir       string
+		paths     []ignoreList
+		types     map[string]bool
+		allow     map[string]bool
+		deny      map[string]bool
+		denyPipe  bool
+		denyLines []*regexp.Regexp
+		message   string
+	}
+)
+
+var (
+	// downloaders print what they download with the usual options.
+	downloaders = toSet([]string{"curl", "wget", "fetch", "aria2c", "http", "https", "xh"})
+	// shells run the script they read from stdin.
+	shells = toSet([]string{"sh", "bash", "dash", "zsh", "ksh", "mksh", "ash", "busybox", "fish"})
+)
+
+// extractorTypes are the names extractorType returns.
+var extractorTypes = []string{
+	"shell", "makefile", "dockerfile", "earthfile", "yaml", "markdown", "notebook",
+	"go", "python", "node", "ruby", "rust", "nix", "pre-commit", "lefthook",
+	"git-hook", "systemd", "crontab", "supervisord",
+}
+
+// extractorType returns the name of the extractor of the file at path, for
+// PolicyRule.Types.
+func extractorType(path string) string {
+	if i := strings.LastIndex(path, archiveSep); i >= 0 {
+		path = path[i+len(archiveSep):]
+	}
+	switch ext := GetExtractor(path).(type) {
+	case *MakefileExtractor:
+		return "makefile"
+	case *DockerfileExtractor:
+		if ext.Earthfile {
+			return "earthfile"
+		}
+		return "dockerfile"
+	case *YAMLExtractor:
+		return "yaml"
+	case *PreCommitExtractor:
+		return "pre-commit"
+	case *LefthookExtractor:
+		return "lefthook"
+	case *GitHookExtractor:
+		return "git-hook"
+	case *MarkdownExtractor:
+		return "markdown"
+	case *NotebookExtractor:
+		return "notebook"
+	case *NixExtractor:
+		return "nix"
+	case *SystemdExtractor:
+		return "systemd"
+	case *CrontabExtractor:
+		return "crontab"
+	case *SupervisordExtractor:
+		return "supervisord"
+	case multiExtractor, *GoExtractor:
+		return "go"
+	case *PythonExtractor:
+		return "python"
+	case *NodeExtractor:
+		return "node"
+	case *RubyExtractor:
+		return "ruby"
+	case *RustExtractor:
+		return "rust"
+	}
+	return "shell"
+}
+
+// toSet returns the set of the strings of list.
+func toSet(list []string) map[string]bool {
+	set := make(map[string]bool, len(list))
+	for _, s := range list {
+		set[s] = true
+	}
+	return set
+}
+
+// policyRules compiles the rules of the policy, with Denies as a rule
+// applying everywhere.
+func (c *Config) policyRules() ([]policyRule, error) {
+	dir, err := filepath.Abs(cmp.Or(c.PolicyDir, "."))
+	if err != nil {
+		return nil, err
+	}
+
+	var rules []policyRule
+	if len(c.Denies) > 0 {
+		rules = append(rules, policyRule{index: -1, deny: toSet(c.Denies)})
+	}
+	for i, pr := range c.Policy {
+		rule := policyRule{
+			index:    i,
+			dir:      dir,
+			types:    toSet(pr.Types),
+			allow:    toSet(pr.Allow),
+			deny:     toSet(pr.Deny),
+			denyPipe: pr.DenyPipeToShell,
+			message:  pr.Message,
+		}
+		for _, typ := range pr.Types {
+			if !slices.Contains(extractorTypes, typ) {
+				return nil, fmt.Errorf("policy rule %d: unknown type %q, expected one of %s", i+1, typ, strings.Join(extractorTypes, ", "))
+			}
+		}
+		if len(pr.Paths) > 0 {
+			rule.paths = []ignoreList{{dir: ".", patterns: parseIgnore(pr.Paths)}}
+		}
+		for _, expr := range pr.DenyLines {
+			re, err := regexp.Compile(expr)
+			if err != nil {
+				return nil, fmt.Errorf("policy rule %d: %w", i+1, err)
+			}
+			rule.denyLines = append(rule.denyLines, re)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// appliesTo reports whether the rule applies to the file at path. Paths
+// match relative to the directory of the rule, so files outside it only
+// match rules without Paths.
+func (rule *policyRule) appliesTo(path string) bool {
+	if len(rule.types) > 0 && !rule.types[extractorType(path)] {
+		return false
+	}
+	if rule.paths == nil {
+		return true
+	}
+	// Files of an archive also match by their path inside it
+	if i := strings.LastIndex(path, archiveSep); i >= 0 && excludedPath(path[i+len(archiveSep):], false, rule.paths) {
+		return true
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(rule.dir, abs)
+	if err != nil || !filepath.IsLocal(rel) {
+		return false
+	}
+	return excludedPath(filepath.ToSlash(rel), false, rule.paths)
+}
+
+// CheckPolicy evaluates the policy, Config.Policy and Config.Denies, over r.
+// Each use of a command breaks a rule at most once, and each line a
+// DenyLines expression at most once.
+func (r ScanResult) CheckPolicy(c *Config) (Violations, error) {
+	rules, err := c.policyRules()
+	if err != nil {
+		return nil, err
+	}
+
+	violations := Violations{}
+	for _, path := range slices.Sorted(maps.Keys(r)) {
+		cmds := r[path]
+		for _, rule := range rules {
+			if !rule.appliesTo(path) {
+				continue
+			}
+
+			// The first occurrence of each line, for DenyLines
+			lines := make(map[int]Violation)
+			for cmd, occs := range cmds {
+				for _, occ := range occs {
+					v := Violation{
+						Path:     path,
+						Command:  cmd,
+						Line:     occ.Line,
+						Col:      occ.Col,
+						FullLine: occ.FullLine,
+						Context:  occ.Context,
+						Rule:     rule.index,
+						Message:  rule.message,
+					}
+					switch {
+					case rule.deny[cmd]:
+						if v.Message == "" {
+							v.Message = fmt.Sprintf("%q is denied", cmd)
+						}
+						violations = append(violations, v)
+					case len(rule.allow) > 0 && !rule.allow[cmd] && !builtins[cmd]:
+						if v.Message == "" {
+							v.Message = fmt.Sprintf("%q is not allowed", cmd)
+						}
+						violations = append(violations, v)
+					case rule.denyPipe && downloaders[filepath.Base(cmd)] && shells[filepath.Base(occ.PipedTo)]:
+						if v.Message == "" {
+							v.Message = fmt.Sprintf("%q is piped into %q", cmd, occ.PipedTo)
+						}
+						violations = append(violations, v)
+					}
+
+					key := occ.Line
+					if first, ok := lines[key]; !ok || occ.Col < first.Col {
+						lines[key] = v
+					}
+				}
+			}
+
+			for _, v := range lines {
+				for _, re := range rule.denyLines {
+					if re.MatchString(v.FullLine) {
+						if rule.message == "" {
+							v.Message = fmt.Sprintf("line matches %q", re.String())
+						} else {
+							v.Message = rule.message
+						}
+						violations = append(violations, v)
+						break
+					}
+				}
+			}
+		}
+	}
+
+	slices.SortStableFunc(violations, func(a, b Violation) int {
+		return cmp.Or(
+			cmp.Compare(a.Path, b.Path),
+			cmp.Compare(a.Line, b.Line),
+			cmp.Compare(a.Col, b.Col),
+			cmp.Compare(a.Rule, b.Rule),
+			cmp.Compare(a.Message, b.Message),
+		)
+	})
+	return violations, nil
+}
+
+// Format returns a line per violation, "path:line:col: message", followed by
+// the offending line with ShowPos.
+func (vs Violations) Format(c *Config) string {
+	var sb strings.Builder
+	for _, v := range vs {
+		loc := fmt.Sprintf("%s:%d:%d", v.Path, v.Line, runeColumn(v.FullLine, v.Col))
+		if v.Context != "" {
+			loc += " [" + v.Context + "]"
+		}
+		msg := v.Message
+		if c.UseColor {
+			loc = colorCyan + loc + colorReset
+			msg = colorRed + msg + colorReset
+		}
+		fmt.Fprintf(&sb, "%s: %s\n", loc, msg)
+		if c.ShowPos {
+			fmt.Fprintf(&sb, "    %s\n", strings.TrimSpace(v.FullLine))
+		}
+	}
+	return sb.String()
+}
+
+// JSON returns the JSON encoding of the violations.
+func (vs Violations) JSON() (string, error) {
+	b, err := json.MarshalIndent(vs, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// YAML returns the YAML encoding of the violations.
+func (vs Violations) YAML() (string, error) {
+	b, err := yaml.Marshal(vs)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
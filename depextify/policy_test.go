@@ -0,0 +1,140 @@
+package depextify
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResult_CheckPolicy(t *testing.T) {
+	res := ScanResult{
+		"scripts/ci/setup.sh": {
+			"curl": {{Line: 1, Col: 1, FullLine: "curl -fsSL https://example.com/install | sh"}},
+			"sh":   {{Line: 1, Col: 43, FullLine: "curl -fsSL https://example.com/install | sh"}},
+			"sudo": {{Line: 2, Col: 1, FullLine: "sudo apt-get install -y jq"}},
+		},
+		"scripts/dev.sh": {
+			"sudo": {{Line: 1, Col: 1, FullLine: "sudo make install"}},
+		},
+		"deploy/run.sh": {
+			"echo":    {{Line: 1, Col: 1, FullLine: "echo deploying"}},
+			"aws":     {{Line: 2, Col: 1, FullLine: "aws s3 sync . s3://bucket"}},
+			"kubectl": {{Line: 3, Col: 3, FullLine: "  kubectl apply -f k8s"}},
+		},
+		"deploy/Dockerfile": {
+			"kubectl": {{Line: 4, Col: 5, FullLine: "RUN kubectl version"}},
+		},
+	}
+
+	c := &Config{
+		Denies: []string{"aws"},
+		Policy: []PolicyRule{
+			{DenyLines: []string{`curl[^|]*\|\s*(ba)?sh`}, Message: "do not pipe curl into a shell"},
+			{Paths: []string{"scripts/ci/"}, Deny: []string{"sudo"}},
+			{Paths: []string{"deploy/**"}, Types: []string{"shell"}, Allow: []string{"aws"}},
+		},
+	}
+	violations, err := res.CheckPolicy(c)
+	require.NoError(t, err)
+	require.Equal(t, Violations{
+		{Path: "deploy/run.sh", Command: "aws", Line: 2, Col: 1, FullLine: "aws s3 sync . s3://bucket", Rule: -1, Message: `"aws" is denied`},
+		{Path: "deploy/run.sh", Command: "kubectl", Line: 3, Col: 3, FullLine: "  kubectl apply -f k8s", Rule: 2, Message: `"kubectl" is not allowed`},
+		{Path: "scripts/ci/setup.sh", Command: "curl", Line: 1, Col: 1, FullLine: "curl -fsSL https://example.com/install | sh", Rule: 0, Message: "do not pipe curl into a shell"},
+		{Path: "scripts/ci/setup.sh", Command: "sudo", Line: 2, Col: 1, FullLine: "sudo apt-get install -y jq", Rule: 1, Message: `"sudo" is denied`},
+	}, violations)
+
+	require.Equal(t, `deploy/run.sh:2:1: "aws" is denied
+deploy/run.sh:3:3: "kubectl" is not allowed
+`, violations[:2].Format(&Config{}))
+	require.Equal(t, `deploy/run.sh:3:3: "kubectl" is not allowed
+    kubectl apply -f k8s
+`, violations[1:2].Format(&Config{ShowPos: true}))
+
+	t.Run("no violations", func(t *testing.T) {
+		violations, err := res.CheckPolicy(&Config{Policy: []PolicyRule{{Paths: []string{"*.py"}, Deny: []string{"sudo"}}}})
+		require.NoError(t, err)
+		require.Empty(t, violations)
+		require.Empty(t, violations.Format(&Config{}))
+	})
+
+	t.Run("scanned paths", func(t *testing.T) {
+		repo := t.TempDir()
+		script := filepath.Join(repo, "scripts", "ci", "setup.sh")
+		require.NoError(t, os.MkdirAll(filepath.Dir(script), 0o755))
+		require.NoError(t, os.WriteFile(script, []byte("sudo make install\n"), 0o600))
+		rule := PolicyRule{Paths: []string{"scripts/ci/"}, Deny: []string{"sudo"}}
+
+		// Absolute targets match relative to PolicyDir
+		res, err := (&Config{}).Scan(repo)
+		require.NoError(t, err)
+		violations, err := res.CheckPolicy(&Config{Policy: []PolicyRule{rule}, PolicyDir: repo})
+		require.NoError(t, err)
+		require.Len(t, violations, 1)
+		require.Equal(t, script, violations[0].Path)
+
+		// Files outside PolicyDir do not match
+		violations, err = res.CheckPolicy(&Config{Policy: []PolicyRule{rule}, PolicyDir: filepath.Join(repo, "scripts")})
+		require.NoError(t, err)
+		require.Empty(t, violations)
+
+		// Files of archives match by their path inside it too
+		violations, err = ScanResult{
+			"/elsewhere/bundle.tar!/scripts/ci/setup.sh": {"sudo": {{Line: 1, Col: 1, FullLine: "sudo make install"}}},
+		}.CheckPolicy(&Config{Policy: []PolicyRule{rule}, PolicyDir: repo})
+		require.NoError(t, err)
+		require.Len(t, violations, 1)
+	})
+
+	t.Run("pipe to shell", func(t *testing.T) {
+		dir := t.TempDir()
+		script := filepath.Join(dir, "install.sh")
+		require.NoError(t, os.WriteFile(script, []byte(`#!/bin/sh
+curl -fsSL https://example.com/install \
+  | sh
+echo "never curl x | sh"
+wget -qO- https://example.com/setup | sudo -E bash -s -- --yes
+curl -fsSL https://example.com/key | gpg --dearmor
+`), 0o600))
+
+		res, err := (&Config{}).Scan(script)
+		require.NoError(t, err)
+		violations, err := res.CheckPolicy(&Config{Policy: []PolicyRule{{DenyPipeToShell: true}}})
+		require.NoError(t, err)
+		require.Equal(t, Violations{
+			{Path: script, Command: "curl", Line: 2, Col: 1, FullLine: "curl -fsSL https://example.com/install \\", Message: `"curl" is piped into "sh"`},
+			{Path: script, Command: "wget", Line: 5, Col: 1, FullLine: "wget -qO- https://example.com/setup | sudo -E bash -s -- --yes", Message: `"wget" is piped into "bash"`},
+		}, violations)
+	})
+
+	t.Run("invalid expression", func(t *testing.T) {
+		_, err := res.CheckPolicy(&Config{Policy: []PolicyRule{{DenyLines: []string{"("}}}})
+		require.Error(t, err)
+	})
+
+	t.Run("unknown type", func(t *testing.T) {
+		_, err := res.CheckPolicy(&Config{Policy: []PolicyRule{{Types: []string{"shell", "Shell"}, Deny: []string{"sudo"}}}})
+		require.ErrorContains(t, err, `policy rule 1: unknown type "Shell"`)
+	})
+}
+
+func TestExtractorType(t *testing.T) {
+	for path, typ := range map[string]string{
+		"run.sh":                      "shell",
+		"bin/tool":                    "shell",
+		"Makefile":                    "makefile",
+		"Dockerfile":                  "dockerfile",
+		".github/workflows/ci.yml":    "yaml",
+		"main.go":                     "go",
+		"release.tar.gz!/Dockerfile":  "dockerfile",
+		"release.tar.gz!/bin/install": "shell",
+		"Earthfile":                   "earthfile",
+		"analysis.ipynb":              "notebook",
+		".pre-commit-config.yaml":     "pre-commit",
+		"deploy/app.service":          "systemd",
+	} {
+		require.Equal(t, typ, extractorType(path), path)
+		require.Contains(t, extractorTypes, typ)
+	}
+}
@@ -0,0 +1,211 @@
+package depextify
+
+import (
+	"fmt"
+	"html/template"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/styles"
+)
+
+type (
+	// htmlReport is the data of the HTML report template.
+	htmlReport struct {
+		Version   string
+		CodeStyle template.CSS
+		Commands  []CommandUsage
+		Files     []htmlFile
+	}
+
+	htmlFile struct {
+		Path     string
+		Search   string
+		Commands []htmlCommand
+	}
+
+	htmlCommand struct {
+		Name  string
+		Lines []htmlLine
+	}
+
+	htmlLine struct {
+		Line    int
+		Context string
+		Code    template.HTML
+	}
+)
+
+var htmlTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>depextify report</title>
+<style>
+body { font-family: system-ui, sans-serif; margin: 2rem auto; max-width: 72rem; padding: 0 1rem; color: #222; }
+input[type=search] { width: 100%; padding: .5rem; font-size: 1rem; box-sizing: border-box; }
+table { border-collapse: collapse; margin: .5rem 0 1rem; }
+th, td { border: 1px solid #ccc; padding: .25rem .75rem; text-align: left; vertical-align: top; }
+td.num { text-align: right; }
+details { border: 1px solid #ccc; border-radius: 4px; margin: .5rem 0; padding: .5rem 1rem; }
+summary { cursor: pointer; font-family: monospace; font-weight: bold; }
+pre { margin: 0; padding: .5rem; overflow-x: auto; }
+mark { color: inherit; background: none; font-weight: bold; text-decoration: underline; }
+.ln { color: #888; user-select: none; }
+.ctx { color: #888; }
+footer { color: #888; font-size: .875rem; margin-top: 2rem; }
+</style>
+</head>
+<body>
+<h1>Dependencies</h1>
+<input type="search" id="search" placeholder="Filter by command or path" autofocus>
+{{- if .Commands}}
+<h2>Commands</h2>
+<table>
+<thead><tr><th>Command</th><th>Files</th><th>Occurrences</th></tr></thead>
+<tbody>
+{{- range .Commands}}
+<tr data-search="{{.Command}}"><td><code>{{.Command}}</code></td><td class="num">{{len .Files}}</td><td class="num">{{.Count}}</td></tr>
+{{- end}}
+</tbody>
+</table>
+<h2>Files</h2>
+{{- range .Files}}
+<details open data-search="{{.Search}}">
+<summary>{{.Path}}</summary>
+<table>
+<thead><tr><th>Command</th><th>Count</th><th>Lines</th></tr></thead>
+<tbody>
+{{- range .Commands}}
+<tr><td><code>{{.Name}}</code></td><td class="num">{{len .Lines}}</td><td><pre style="{{$.CodeStyle}}">
+{{- range $i, $l := .Lines}}{{if $i}}
+{{end}}<span class="ln">{{$l.Line}}:</span> {{if $l.Context}}<span class="ctx">[{{$l.Context}}]</span> {{end}}{{$l.Code}}{{end -}}
+</pre></td></tr>
+{{- end}}
+</tbody>
+</table>
+</details>
+{{- end}}
+{{- else}}
+<p>No commands found.</p>
+{{- end}}
+<footer>Generated by depextify {{.Version}}</footer>
+<script>
+document.getElementById("search").addEventListener("input", (e) => {
+  const q = e.target.value.trim().toLowerCase();
+  for (const el of document.querySelectorAll("[data-search]")) {
+    el.hidden = q !== "" && !el.dataset.search.toLowerCase().includes(q);
+  }
+});
+</script>
+</body>
+</html>
+`))
+
+// mdCode returns s as a Markdown code span that can be put in a table cell.
+func mdCode(s string) string {
+	s = strings.ReplaceAll(s, "|", `\|`)
+	if strings.Contains(s, "`") {
+		return "`` " + s + " ``"
+	}
+	return "`" + s + "`"
+}
+
+// mdLines returns the line numbers of occs, with their context if any.
+func mdLines(occs []Occurrence) string {
+	ls := make([]string, 0, len(occs))
+	for _, occ := range occs {
+		l := strconv.Itoa(occ.Line)
+		if occ.Context != "" {
+			l += " (" + occ.Context + ")"
+		}
+		ls = append(ls, l)
+	}
+	return strings.Join(ls, ", ")
+}
+
+// Markdown returns the result as Markdown: a table of the commands, sorted by
+// SortBy, then a table of the commands of each file.
+func (r ScanResult) Markdown(c *Config) string {
+	var sb strings.Builder
+	sb.WriteString("# Dependencies\n\n")
+
+	usages := r.ByCommand(c.SortBy)
+	if len(usages) == 0 {
+		sb.WriteString("No commands found.\n")
+		return sb.String()
+	}
+
+	sb.WriteString("## Commands\n\n| Command | Files | Occurrences |\n| --- | ---: | ---: |\n")
+	for _, u := range usages {
+		fmt.Fprintf(&sb, "| %s | %d | %d |\n", mdCode(u.Command), len(u.Files), u.Count)
+	}
+
+	sb.WriteString("\n## Files\n")
+	for _, path := range slices.Sorted(maps.Keys(r)) {
+		fmt.Fprintf(&sb, "\n### %s\n\n| Command | Count | Lines |\n| --- | ---: | --- |\n", mdCode(path))
+		for _, cmd := range slices.Sorted(maps.Keys(r[path])) {
+			occs := r[path][cmd]
+			fmt.Fprintf(&sb, "| %s | %d | %s |\n", mdCode(cmd), len(occs), strings.ReplaceAll(mdLines(occs), "|", `\|`))
+		}
+	}
+	return sb.String()
+}
+
+// HTML returns the result as a self-contained HTML page: a table of the
+// commands, sorted by SortBy, then a collapsible section per file with the
+// lines using each command, highlighted with LexerName and StyleName.
+func (r ScanResult) HTML(c *Config) (string, error) {
+	style := styles.Get(c.StyleName)
+	if style == nil {
+		style = styles.Get(DefaultStyle)
+	}
+	var codeStyle []string
+	bg := style.Get(chroma.Background)
+	if bg.Colour.IsSet() {
+		codeStyle = append(codeStyle, "color:"+bg.Colour.String())
+	}
+	if bg.Background.IsSet() {
+		codeStyle = append(codeStyle, "background-color:"+bg.Background.String())
+	}
+
+	report := htmlReport{
+		Version:   Version(),
+		CodeStyle: template.CSS(strings.Join(codeStyle, ";")),
+		Commands:  r.ByCommand(c.SortBy),
+	}
+	for _, path := range slices.Sorted(maps.Keys(r)) {
+		f := htmlFile{Path: path, Search: path}
+		for _, cmd := range slices.Sorted(maps.Keys(r[path])) {
+			f.Search += " " + cmd
+			hc := htmlCommand{Name: cmd}
+			for _, occ := range r[path][cmd] {
+				// Drop the indentation, keeping the command in place
+				code := strings.TrimLeft(occ.FullLine, " \t")
+				start := occ.Col - 1 - (len(occ.FullLine) - len(code))
+				code = strings.TrimRight(code, " \t\r")
+				hl := &highlightRange{start: start, end: start + occ.Len}
+				if start < 0 || hl.end > len(code) {
+					hl = nil
+				}
+				hc.Lines = append(hc.Lines, htmlLine{
+					Line:    occ.Line,
+					Context: occ.Context,
+					Code:    template.HTML(highlightCode(code, c.lexerName(path), c.StyleName, hl, htmlFormatter)),
+				})
+			}
+			f.Commands = append(f.Commands, hc)
+		}
+		report.Files = append(report.Files, f)
+	}
+
+	var sb strings.Builder
+	if err := htmlTemplate.Execute(&sb, report); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
@@ -0,0 +1,61 @@
+package depextify
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResult_Markdown(t *testing.T) {
+	res := ScanResult{
+		"deploy.sh": {
+			"jq":  {{Line: 3, Col: 10, Len: 2, FullLine: "cat x.json | jq .a"}, {Line: 7, Col: 1, Len: 2, FullLine: "jq -r .b"}},
+			"aws": {{Line: 1, Col: 1, Len: 3, FullLine: "aws s3 ls"}},
+		},
+		"notebook.ipynb": {
+			"jq": {{Line: 12, Col: 1, Len: 2, FullLine: "jq .", Context: "cell 2:1"}},
+		},
+	}
+
+	require.Equal(t, "# Dependencies\n\n"+
+		"## Commands\n\n"+
+		"| Command | Files | Occurrences |\n| --- | ---: | ---: |\n"+
+		"| `jq` | 2 | 3 |\n"+
+		"| `aws` | 1 | 1 |\n\n"+
+		"## Files\n\n"+
+		"### `deploy.sh`\n\n"+
+		"| Command | Count | Lines |\n| --- | ---: | --- |\n"+
+		"| `aws` | 1 | 1 |\n"+
+		"| `jq` | 2 | 3, 7 |\n\n"+
+		"### `notebook.ipynb`\n\n"+
+		"| Command | Count | Lines |\n| --- | ---: | --- |\n"+
+		"| `jq` | 1 | 12 (cell 2:1) |\n",
+		res.Markdown(&Config{}))
+
+	require.Equal(t, "# Dependencies\n\nNo commands found.\n", ScanResult{}.Markdown(&Config{}))
+	require.Equal(t, "`a\\|b`", mdCode("a|b"))
+	require.Equal(t, "`` a`b ``", mdCode("a`b"))
+}
+
+func TestResult_HTML(t *testing.T) {
+	res := ScanResult{
+		"deploy.sh": {
+			"jq": {{Line: 3, Col: 16, Len: 2, FullLine: "  echo '<a>' | jq .a"}},
+		},
+	}
+
+	out, err := res.HTML(&Config{LexerName: DefaultLexer, StyleName: DefaultStyle})
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(out, "<!DOCTYPE html>"))
+	require.Contains(t, out, `<tr data-search="jq"><td><code>jq</code></td><td class="num">1</td><td class="num">1</td></tr>`)
+	require.Contains(t, out, `<details open data-search="deploy.sh jq">`)
+	require.Contains(t, out, `<span class="ln">3:</span> echo <span style="color:#e6db74">&#39;&lt;a&gt;&#39;</span> | <mark>jq</mark> .a</pre>`)
+	// Self-contained
+	require.NotContains(t, out, "<link")
+	require.NotContains(t, out, "src=")
+
+	out, err = ScanResult{}.HTML(&Config{})
+	require.NoError(t, err)
+	require.Contains(t, out, "<p>No commands found.</p>")
+}
@@ -0,0 +1,249 @@
+package depextify
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// RootPath is the PATH commands are looked up in a root filesystem by
+// default, the one of most container images.
+const RootPath = "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+
+type (
+	// rootEntry is a file of a root filesystem.
+	rootEntry struct {
+		mode fs.FileMode
+		// link is the target of a symlink.
+		link string
+	}
+
+	// rootLayer holds the entries of an image layer, or of a tarball of a
+	// whole root filesystem.
+	rootLayer struct {
+		entries   map[string]rootEntry
+		whiteouts []string
+		opaques   []string
+	}
+
+	// rootFS looks up the entries of a root filesystem by their path relative
+	// to its root, "" being the root itself.
+	rootFS func(name string) (rootEntry, bool)
+)
+
+// add records a layer entry.
+func (l *rootLayer) add(hdr *tar.Header) {
+	name := cleanEntryName(hdr.Name)
+	base := path.Base(name)
+	switch {
+	case name == "":
+	case base == whiteoutOpaque:
+		l.opaques = append(l.opaques, path.Dir(name))
+	case strings.HasPrefix(base, whiteoutPrefix):
+		l.whiteouts = append(l.whiteouts, path.Join(path.Dir(name), base[len(whiteoutPrefix):]))
+	default:
+		// Hard links are regular files for FileInfo
+		entry := rootEntry{mode: hdr.FileInfo().Mode()}
+		if hdr.Typeflag == tar.TypeSymlink {
+			entry.link = hdr.Linkname
+		}
+		l.entries[name] = entry
+	}
+}
+
+// applyTo deletes the paths of root the layer deletes, then adds its
+// entries.
+func (l *rootLayer) applyTo(root map[string]rootEntry) {
+	for name := range root {
+		for _, p := range l.whiteouts {
+			if _, ok := within(p, name); ok || name == p {
+				delete(root, name)
+			}
+		}
+		for _, dir := range l.opaques {
+			if _, ok := within(dir, name); ok {
+				delete(root, name)
+			}
+		}
+	}
+	for name, entry := range l.entries {
+		root[name] = entry
+	}
+}
+
+// readRootTar returns the entries of the root filesystem in a tarball: the
+// root filesystem of a container image, or of the tarball itself, e.g. the
+// output of `docker export`.
+func readRootTar(fsys fs.FS, archive string) (map[string]rootEntry, error) {
+	layers, err := readImageLayers(fsys, archive)
+	if err != nil {
+		return nil, err
+	}
+
+	root := make(map[string]rootEntry)
+	if layers == nil {
+		l := &rootLayer{entries: root}
+		err := walkTarEntries(fsys, archive, func(hdr *tar.Header, _ io.Reader) error {
+			l.add(hdr)
+			return nil
+		})
+		return root, err
+	}
+
+	index := make(map[string]int, len(layers))
+	entries := make([]*rootLayer, len(layers))
+	for i, l := range layers {
+		index[l] = i
+		entries[i] = &rootLayer{entries: make(map[string]rootEntry)}
+	}
+	err = walkTar(fsys, archive, func(hdr *tar.Header, r io.Reader) error {
+		i, ok := index[cleanEntryName(hdr.Name)]
+		if !ok {
+			return nil
+		}
+		lr, err := decompress(r)
+		if err != nil {
+			return fmt.Errorf("layer %s: %w", layers[i], err)
+		}
+		err = eachTarEntry(tar.NewReader(lr), func(hdr *tar.Header, _ io.Reader) error {
+			entries[i].add(hdr)
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("layer %s: %w", layers[i], err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Lowest layer first
+	for _, l := range entries {
+		l.applyTo(root)
+	}
+	return root, nil
+}
+
+// openRoot returns the root filesystem in a directory of fsys, or in a
+// tarball.
+func openRoot(fsys fs.FS, root string) (rootFS, error) {
+	info, err := fs.Stat(fsys, root)
+	if err != nil {
+		return nil, err
+	}
+
+	if !info.IsDir() {
+		entries, err := readRootTar(fsys, root)
+		if err != nil {
+			return nil, err
+		}
+		// Tarballs may leave out directories
+		for name := range entries {
+			for dir := path.Dir(name); dir != "."; dir = path.Dir(dir) {
+				if _, ok := entries[dir]; !ok {
+					entries[dir] = rootEntry{mode: fs.ModeDir}
+				}
+			}
+		}
+		entries[""] = rootEntry{mode: fs.ModeDir}
+		return func(name string) (rootEntry, bool) {
+			entry, ok := entries[name]
+			return entry, ok
+		}, nil
+	}
+
+	return func(name string) (rootEntry, bool) {
+		p := joinPath(fsys, root, name)
+		info, err := fs.Lstat(fsys, p)
+		if err != nil {
+			return rootEntry{}, false
+		}
+		entry := rootEntry{mode: info.Mode()}
+		if entry.mode&fs.ModeSymlink != 0 {
+			if entry.link, err = fs.ReadLink(fsys, p); err != nil {
+				return rootEntry{}, false
+			}
+		}
+		return entry, true
+	}, nil
+}
+
+// resolve follows the symlinks of the absolute path p without leaving the
+// root filesystem: absolute targets and ".." are relative to its root. It
+// returns the resolved path and its entry.
+func (rfs rootFS) resolve(p string) (string, rootEntry, bool) {
+	parts := strings.Split(p, "/")
+	cur := "/"
+	for links := 0; len(parts) > 0; {
+		part := parts[0]
+		parts = parts[1:]
+		if part == "" || part == "." {
+			continue
+		}
+		next := path.Join(cur, part)
+		entry, ok := rfs(next[1:])
+		if !ok {
+			return "", rootEntry{}, false
+		}
+		if entry.mode&fs.ModeSymlink == 0 {
+			cur = next
+			continue
+		}
+
+		if links++; links > maxSymlinks {
+			return "", rootEntry{}, false
+		}
+		if strings.HasPrefix(entry.link, "/") {
+			cur = "/"
+		}
+		parts = append(strings.Split(entry.link, "/"), parts...)
+	}
+
+	entry, ok := rfs(cur[1:])
+	return cur, entry, ok
+}
+
+// CheckRoot is Check for the root filesystem of a container: a directory, or
+// a tarball of it or of a container image. pathList is searched inside it,
+// RootPath if empty. Symlinks are followed within the root filesystem, so
+// busybox applets are found.
+func (r ScanResult) CheckRoot(root, pathList string) (Checks, error) {
+	return r.CheckRootFS(osFS{}, root, pathList)
+}
+
+// CheckRootFS is CheckRoot for a root filesystem in fsys.
+func (r ScanResult) CheckRootFS(fsys fs.FS, root, pathList string) (Checks, error) {
+	rfs, err := openRoot(fsys, root)
+	if err != nil {
+		return nil, err
+	}
+	if pathList == "" {
+		pathList = RootPath
+	}
+	dirs := strings.Split(pathList, ":")
+
+	return r.checkCommands(func(cmd string) (string, string, bool) {
+		candidates := make([]string, 0, len(dirs))
+		if strings.Contains(cmd, "/") {
+			candidates = append(candidates, path.Join("/", cmd))
+		} else {
+			for _, dir := range dirs {
+				candidates = append(candidates, path.Join("/", dir, cmd))
+			}
+		}
+		for _, p := range candidates {
+			target, entry, ok := rfs.resolve(p)
+			if ok && entry.mode.IsRegular() && entry.mode&0o111 != 0 {
+				if target == p {
+					target = ""
+				}
+				return p, target, true
+			}
+		}
+		return "", "", false
+	}), nil
+}
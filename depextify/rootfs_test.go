@@ -0,0 +1,99 @@
+package depextify
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResult_CheckRoot(t *testing.T) {
+	res := ScanResult{
+		"entrypoint.sh": {
+			"sh":              {{Line: 1}},
+			"jq":              {{Line: 2}},
+			"evil":            {{Line: 3}},
+			"notes":           {{Line: 4}},
+			"cd":              {{Line: 5}},
+			"curl":            {{Line: 6}},
+			"/opt/app/run.sh": {{Line: 7}},
+		},
+	}
+
+	t.Run("directory", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		root := filepath.Join(tmpDir, "rootfs")
+		for _, dir := range []string{"bin", "usr/bin", "usr/local/bin", "opt/app"} {
+			require.NoError(t, os.MkdirAll(filepath.Join(root, dir), 0o755))
+		}
+		require.NoError(t, os.WriteFile(filepath.Join(root, "bin/busybox"), nil, 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(root, "opt/app/run.sh"), nil, 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(root, "usr/local/bin/notes"), nil, 0o644))
+		require.NoError(t, os.Symlink("busybox", filepath.Join(root, "bin/sh")))
+		require.NoError(t, os.Symlink("/bin/busybox", filepath.Join(root, "usr/bin/jq")))
+		// Resolves to /outside of the root filesystem, which does not exist
+		require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "outside"), nil, 0o755))
+		require.NoError(t, os.Symlink("../../../../outside", filepath.Join(root, "usr/bin/evil")))
+
+		checks, err := res.CheckRoot(root, "")
+		require.NoError(t, err)
+		require.Equal(t, Checks{
+			{Command: "/opt/app/run.sh", Found: true, Path: "/opt/app/run.sh", Files: []string{"entrypoint.sh"}},
+			{Command: "cd", Found: true, Builtin: true, Files: []string{"entrypoint.sh"}},
+			{Command: "curl", Files: []string{"entrypoint.sh"}},
+			{Command: "evil", Files: []string{"entrypoint.sh"}},
+			{Command: "jq", Found: true, Path: "/usr/bin/jq", Target: "/bin/busybox", Files: []string{"entrypoint.sh"}},
+			{Command: "notes", Files: []string{"entrypoint.sh"}},
+			{Command: "sh", Found: true, Path: "/bin/sh", Target: "/bin/busybox", Files: []string{"entrypoint.sh"}},
+		}, checks)
+
+		checks, err = res.CheckRoot(root, "/usr/bin")
+		require.NoError(t, err)
+		require.Equal(t, []string{"curl", "evil", "notes", "sh"}, checks.Missing())
+
+		_, err = res.CheckRoot(filepath.Join(tmpDir, "missing"), "")
+		require.Error(t, err)
+	})
+
+	t.Run("image", func(t *testing.T) {
+		base := buildTar(t, []tarEntry{
+			{name: "bin/busybox"},
+			{name: "bin/sh", link: "busybox"},
+			{name: "usr/bin/curl"},
+			{name: "usr/local/bin/notes", mode: 0o644},
+		}, true)
+		top := buildTar(t, []tarEntry{
+			{name: "usr/bin/.wh.curl"},
+			{name: "usr/bin/jq", link: "../../bin/busybox"},
+			{name: "opt/app/run.sh"},
+		}, false)
+		image := buildTar(t, []tarEntry{
+			{name: "base/layer.tar", content: string(base)},
+			{name: "top/layer.tar", content: string(top)},
+			{name: "manifest.json", content: `[{"Config":"config.json","Layers":["base/layer.tar","top/layer.tar"]}]`},
+		}, false)
+		archive := filepath.Join(t.TempDir(), "image.tar")
+		require.NoError(t, os.WriteFile(archive, image, 0o600))
+
+		checks, err := res.CheckRoot(archive, "")
+		require.NoError(t, err)
+		require.Equal(t, []string{"curl", "evil", "notes"}, checks.Missing())
+		require.Equal(t, "/bin/busybox", checks[4].Target)
+	})
+
+	t.Run("rootfs tarball", func(t *testing.T) {
+		rootfs := buildTar(t, []tarEntry{
+			{name: "./bin/", dir: true},
+			{name: "./bin/busybox"},
+			{name: "./bin/sh", link: "/bin/busybox"},
+			{name: "./usr/bin/curl"},
+		}, true)
+		archive := filepath.Join(t.TempDir(), "rootfs.tar.gz")
+		require.NoError(t, os.WriteFile(archive, rootfs, 0o600))
+
+		checks, err := res.CheckRoot(archive, "")
+		require.NoError(t, err)
+		require.Equal(t, []string{"/opt/app/run.sh", "evil", "jq", "notes"}, checks.Missing())
+	})
+}
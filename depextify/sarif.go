@@ -0,0 +1,260 @@
+package depextify
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+	sarifVersion = "2.1.0"
+	// sarifFingerprint names the partial fingerprint of results, versioned in
+	// case the way it is computed changes.
+	sarifFingerprint = "depextify/v1"
+	infoURI          = "https://github.com/Nymphium/depextify"
+)
+
+// Categories of commands, which are the rules of SARIF results.
+const (
+	CategoryBuiltin   = "builtin"
+	CategoryCoreutils = "coreutils"
+	CategoryCommon    = "common"
+	CategoryExternal  = "external"
+	CategoryDenied    = "denied"
+)
+
+type (
+	sarifLog struct {
+		Schema  string     `json:"$schema"`
+		Version string     `json:"version"`
+		Runs    []sarifRun `json:"runs"`
+	}
+
+	sarifRun struct {
+		Tool       sarifTool     `json:"tool"`
+		ColumnKind string        `json:"columnKind"`
+		Results    []sarifResult `json:"results"`
+	}
+
+	sarifTool struct {
+		Driver sarifDriver `json:"driver"`
+	}
+
+	sarifDriver struct {
+		Name           string      `json:"name"`
+		Version        string      `json:"version"`
+		InformationURI string      `json:"informationUri"`
+		Rules          []sarifRule `json:"rules"`
+	}
+
+	sarifRule struct {
+		ID                   string       `json:"id"`
+		Name                 string       `json:"name"`
+		ShortDescription     sarifMessage `json:"shortDescription"`
+		DefaultConfiguration struct {
+			Level string `json:"level"`
+		} `json:"defaultConfiguration"`
+	}
+
+	sarifMessage struct {
+		Text string `json:"text"`
+	}
+
+	sarifResult struct {
+		RuleID              string            `json:"ruleId"`
+		RuleIndex           int               `json:"ruleIndex"`
+		Level               string            `json:"level"`
+		Message             sarifMessage      `json:"message"`
+		Locations           []sarifLocation   `json:"locations"`
+		PartialFingerprints map[string]string `json:"partialFingerprints"`
+	}
+
+	sarifLocation struct {
+		PhysicalLocation struct {
+			ArtifactLocation struct {
+				URI string `json:"uri"`
+			} `json:"artifactLocation"`
+			Region *sarifRegion `json:"region,omitempty"`
+		} `json:"physicalLocation"`
+		LogicalLocations []sarifLogicalLocation `json:"logicalLocations,omitempty"`
+	}
+
+	// sarifLogicalLocation locates results inside an artifact: a file of an
+	// archive, or a notebook cell.
+	sarifLogicalLocation struct {
+		Name               string `json:"name"`
+		FullyQualifiedName string `json:"fullyQualifiedName"`
+		Kind               string `json:"kind"`
+	}
+
+	sarifRegion struct {
+		StartLine   int           `json:"startLine"`
+		StartColumn int           `json:"startColumn,omitempty"`
+		EndColumn   int           `json:"endColumn,omitempty"`
+		Snippet     *sarifMessage `json:"snippet,omitempty"`
+	}
+)
+
+// sarifRules are the rules of SARIF results, one per category.
+var sarifRules = []struct {
+	category    string
+	name        string
+	level       string
+	description string
+	// message is the format of the message of results, given the command.
+	message string
+}{
+	{CategoryBuiltin, "ShellBuiltin", "note", "Shell built-in command", "Uses the shell built-in `%s`"},
+	{CategoryCoreutils, "Coreutils", "note", "GNU coreutils command", "Requires the coreutils command `%s`"},
+	{CategoryCommon, "CommonTool", "note", "Common tool found on most systems", "Requires the common tool `%s`"},
+	{CategoryExternal, "ExternalCommand", "warning", "External command that must be installed", "Requires the external command `%s`"},
+	{CategoryDenied, "DeniedCommand", "error", "Command listed in denies", "Uses the denied command `%s`"},
+}
+
+// Category returns the category of cmd: denied if it is listed in Denies,
+// else builtin, coreutils, common or external.
+func (c *Config) Category(cmd string) string {
+	switch {
+	case slices.Contains(c.Denies, cmd):
+		return CategoryDenied
+	case builtins[cmd]:
+		return CategoryBuiltin
+	case coreutils[cmd]:
+		return CategoryCoreutils
+	case common[cmd]:
+		return CategoryCommon
+	default:
+		return CategoryExternal
+	}
+}
+
+// sarifURI returns the URI of the artifact at path.
+func sarifURI(path string) string {
+	p := filepath.ToSlash(path)
+	if filepath.IsAbs(path) {
+		if !strings.HasPrefix(p, "/") {
+			// Windows drive letter
+			p = "/" + p
+		}
+		return (&url.URL{Scheme: "file", Path: p}).String()
+	}
+	return (&url.URL{Path: p}).String()
+}
+
+// runeColumn converts the byte column col of line to a column in Unicode
+// code points.
+func runeColumn(line string, col int) int {
+	if col < 1 || col-1 > len(line) {
+		return col
+	}
+	return utf8.RuneCountInString(line[:col-1]) + 1
+}
+
+// SARIF returns the result as a SARIF 2.1.0 log, with a result per occurrence.
+func (r ScanResult) SARIF(c *Config) (string, error) {
+	driver := sarifDriver{
+		Name:           "depextify",
+		Version:        Version(),
+		InformationURI: infoURI,
+	}
+	ruleIndex := make(map[string]int, len(sarifRules))
+	for i, rule := range sarifRules {
+		sr := sarifRule{
+			ID:               rule.category,
+			Name:             rule.name,
+			ShortDescription: sarifMessage{Text: rule.description},
+		}
+		sr.DefaultConfiguration.Level = rule.level
+		driver.Rules = append(driver.Rules, sr)
+		ruleIndex[rule.category] = i
+	}
+
+	results := []sarifResult{}
+	for _, path := range slices.Sorted(maps.Keys(r)) {
+		// Files of an archive are reported on the archive, the artifact a
+		// viewer can open, and located in it by name.
+		file, member, inArchive := strings.Cut(path, archiveSep)
+		uri := sarifURI(file)
+		var logical []sarifLogicalLocation
+		if inArchive {
+			logical = append(logical, sarifLogicalLocation{Name: member, FullyQualifiedName: filepath.ToSlash(path), Kind: "resource"})
+		}
+		// Lines and columns of notebook occurrences are in their cell, which
+		// is located by name instead.
+		notebook := extractorType(path) == "notebook"
+		for _, cmd := range slices.Sorted(maps.Keys(r[path])) {
+			category := c.Category(cmd)
+			idx := ruleIndex[category]
+			// Occurrences on identical lines are told apart by their order,
+			// so fingerprints do not depend on line numbers.
+			seen := make(map[string]int)
+			for _, occ := range r[path][cmd] {
+				text := strings.TrimSpace(occ.FullLine)
+				n := seen[text]
+				seen[text]++
+
+				res := sarifResult{
+					RuleID:    category,
+					RuleIndex: idx,
+					Level:     sarifRules[idx].level,
+					Message:   sarifMessage{Text: fmt.Sprintf(sarifRules[idx].message, cmd)},
+					PartialFingerprints: map[string]string{
+						sarifFingerprint: sarifHash(path, cmd, occ.Context, text, n),
+					},
+				}
+				var loc sarifLocation
+				loc.PhysicalLocation.ArtifactLocation.URI = uri
+				loc.LogicalLocations = logical
+				if notebook && occ.Context != "" {
+					// The context is "cell <n>:<line>"
+					cell, _, _ := strings.Cut(occ.Context, ":")
+					loc.LogicalLocations = append(slices.Clip(logical), sarifLogicalLocation{Name: cell, FullyQualifiedName: occ.Context, Kind: "element"})
+				}
+				if occ.Line > 0 && !inArchive && !notebook {
+					region := &sarifRegion{StartLine: occ.Line}
+					if occ.Col > 0 {
+						region.StartColumn = runeColumn(occ.FullLine, occ.Col)
+						region.EndColumn = runeColumn(occ.FullLine, occ.Col+occ.Len)
+					}
+					if occ.FullLine != "" {
+						region.Snippet = &sarifMessage{Text: occ.FullLine}
+					}
+					loc.PhysicalLocation.Region = region
+				}
+				res.Locations = []sarifLocation{loc}
+				results = append(results, res)
+			}
+		}
+	}
+
+	log := sarifLog{
+		Schema:  sarifSchema,
+		Version: sarifVersion,
+		Runs: []sarifRun{{
+			Tool:       sarifTool{Driver: driver},
+			ColumnKind: "unicodeCodePoints",
+			Results:    results,
+		}},
+	}
+	b, err := json.MarshalIndent(log, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// sarifHash returns the fingerprint of the n-th occurrence of cmd on a line
+// reading text.
+func sarifHash(path, cmd, context, text string, n int) string {
+	b, _ := json.Marshal([]any{filepath.ToSlash(path), cmd, context, text, n})
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:16])
+}
@@ -0,0 +1,142 @@
+package depextify
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResult_SARIF(t *testing.T) {
+	res := ScanResult{
+		"scripts/deploy.sh": {
+			"curl": {
+				{Line: 3, Col: 3, Len: 4, FullLine: "  curl -o out.tar example.com"},
+				{Line: 7, Col: 1, Len: 4, FullLine: "curl -fsSL example.com | sh"},
+			},
+			"jq": {{Line: 2, Col: 13, Len: 2, FullLine: "echo 'é' | jq ."}},
+			"cd": {{Line: 1, Col: 1, Len: 2, FullLine: "cd /tmp"}},
+		},
+	}
+	config := &Config{Denies: []string{"curl"}}
+
+	out, err := res.SARIF(config)
+	require.NoError(t, err)
+
+	var log struct {
+		Version string
+		Runs    []struct {
+			Tool struct {
+				Driver struct {
+					Name  string
+					Rules []struct{ ID string }
+				}
+			}
+			Results []struct {
+				RuleID              string
+				RuleIndex           int
+				Level               string
+				Message             struct{ Text string }
+				PartialFingerprints map[string]string
+				Locations           []struct {
+					PhysicalLocation struct {
+						ArtifactLocation struct{ URI string }
+						Region           struct{ StartLine, StartColumn, EndColumn int }
+					}
+				}
+			}
+		}
+	}
+	require.NoError(t, json.Unmarshal([]byte(out), &log))
+	require.Equal(t, "2.1.0", log.Version)
+	require.Len(t, log.Runs, 1)
+	run := log.Runs[0]
+
+	require.Equal(t, "depextify", run.Tool.Driver.Name)
+	var rules []string
+	for _, r := range run.Tool.Driver.Rules {
+		rules = append(rules, r.ID)
+	}
+	require.Equal(t, []string{"builtin", "coreutils", "common", "external", "denied"}, rules)
+
+	require.Len(t, run.Results, 4)
+	cd, curl, jq := run.Results[0], run.Results[1], run.Results[3]
+	require.Equal(t, "builtin", cd.RuleID)
+	require.Equal(t, "note", cd.Level)
+	require.Equal(t, "denied", curl.RuleID)
+	require.Equal(t, 4, curl.RuleIndex)
+	require.Equal(t, "error", curl.Level)
+	require.Equal(t, "Uses the denied command `curl`", curl.Message.Text)
+	require.Equal(t, "external", jq.RuleID)
+	require.Equal(t, "warning", jq.Level)
+
+	loc := jq.Locations[0].PhysicalLocation
+	require.Equal(t, "scripts/deploy.sh", loc.ArtifactLocation.URI)
+	require.Equal(t, 2, loc.Region.StartLine)
+	// Columns count code points, not bytes
+	require.Equal(t, 12, loc.Region.StartColumn)
+	require.Equal(t, 14, loc.Region.EndColumn)
+
+	t.Run("stable fingerprints", func(t *testing.T) {
+		fingerprint := func(res ScanResult, i int) string {
+			out, err := res.SARIF(config)
+			require.NoError(t, err)
+			require.NoError(t, json.Unmarshal([]byte(out), &log))
+			return log.Runs[0].Results[i].PartialFingerprints["depextify/v1"]
+		}
+		before := fingerprint(res, 3)
+		require.NotEqual(t, before, fingerprint(res, 2))
+
+		// Moving the line does not change the fingerprint
+		moved := ScanResult{"scripts/deploy.sh": {"jq": {{Line: 20, Col: 13, Len: 2, FullLine: "echo 'é' | jq ."}}}}
+		require.Equal(t, before, fingerprint(moved, 0))
+
+		changed := ScanResult{"scripts/deploy.sh": {"jq": {{Line: 2, Col: 13, Len: 2, FullLine: "echo 'é' | jq -r ."}}}}
+		require.NotEqual(t, before, fingerprint(changed, 0))
+	})
+
+	located := func(t *testing.T, res ScanResult) (string, []map[string]string, bool) {
+		out, err := res.SARIF(config)
+		require.NoError(t, err)
+		var log struct {
+			Runs []struct {
+				Results []struct {
+					Locations []struct {
+						PhysicalLocation struct {
+							ArtifactLocation struct{ URI string }
+							Region           *struct{ StartLine int }
+						}
+						LogicalLocations []map[string]string
+					}
+				}
+			}
+		}
+		require.NoError(t, json.Unmarshal([]byte(out), &log))
+		loc := log.Runs[0].Results[0].Locations[0]
+		return loc.PhysicalLocation.ArtifactLocation.URI, loc.LogicalLocations, loc.PhysicalLocation.Region != nil
+	}
+
+	t.Run("notebooks", func(t *testing.T) {
+		// Positions are in the cell, which the region of the file cannot point to
+		nb := ScanResult{"analysis.ipynb": {"curl": {{Line: 12, Col: 2, Len: 4, FullLine: "!curl -O data.csv", Context: "cell 2:1"}}}}
+		uri, logical, region := located(t, nb)
+		require.Equal(t, "analysis.ipynb", uri)
+		require.False(t, region)
+		require.Equal(t, []map[string]string{{"name": "cell 2", "fullyQualifiedName": "cell 2:1", "kind": "element"}}, logical)
+	})
+
+	t.Run("archives", func(t *testing.T) {
+		ar := ScanResult{"dist/release.tar.gz!/bin/install.sh": {"curl": {{Line: 3, Col: 1, Len: 4, FullLine: "curl -O x"}}}}
+		uri, logical, region := located(t, ar)
+		require.Equal(t, "dist/release.tar.gz", uri)
+		require.False(t, region)
+		require.Equal(t, []map[string]string{{"name": "bin/install.sh", "fullyQualifiedName": "dist/release.tar.gz!/bin/install.sh", "kind": "resource"}}, logical)
+
+		// A notebook of an archive has both
+		ar = ScanResult{"nb.zip!/analysis.ipynb": {"curl": {{Line: 12, Col: 2, Len: 4, Context: "cell 2:1"}}}}
+		uri, logical, _ = located(t, ar)
+		require.Equal(t, "nb.zip", uri)
+		require.Len(t, logical, 2)
+		require.Equal(t, "cell 2", logical[1]["name"])
+	})
+}
@@ -0,0 +1,295 @@
+package depextify
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	cycloneDXVersion = "1.6"
+	spdxVersion      = "SPDX-2.3"
+)
+
+var reSPDXID = regexp.MustCompile(`[^A-Za-z0-9.-]+`)
+
+type (
+	cdxBOM struct {
+		BOMFormat    string         `json:"bomFormat"`
+		SpecVersion  string         `json:"specVersion"`
+		SerialNumber string         `json:"serialNumber"`
+		Version      int            `json:"version"`
+		Metadata     cdxMetadata    `json:"metadata"`
+		Components   []cdxComponent `json:"components"`
+	}
+
+	cdxMetadata struct {
+		Timestamp string `json:"timestamp"`
+		Tools     struct {
+			Components []cdxComponent `json:"components"`
+		} `json:"tools"`
+	}
+
+	cdxComponent struct {
+		Type       string        `json:"type"`
+		BOMRef     string        `json:"bom-ref,omitempty"`
+		Name       string        `json:"name"`
+		Version    string        `json:"version,omitempty"`
+		PURL       string        `json:"purl,omitempty"`
+		Properties []cdxProperty `json:"properties,omitempty"`
+		Evidence   *cdxEvidence  `json:"evidence,omitempty"`
+	}
+
+	cdxProperty struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+	}
+
+	cdxEvidence struct {
+		Occurrences []cdxOccurrence `json:"occurrences"`
+	}
+
+	cdxOccurrence struct {
+		Location          string `json:"location"`
+		Line              int    `json:"line,omitempty"`
+		Symbol            string `json:"symbol,omitempty"`
+		AdditionalContext string `json:"additionalContext,omitempty"`
+	}
+
+	spdxDocument struct {
+		SPDXVersion       string             `json:"spdxVersion"`
+		DataLicense       string             `json:"dataLicense"`
+		SPDXID            string             `json:"SPDXID"`
+		Name              string             `json:"name"`
+		DocumentNamespace string             `json:"documentNamespace"`
+		CreationInfo      spdxCreationInfo   `json:"creationInfo"`
+		Packages          []spdxPackage      `json:"packages"`
+		Relationships     []spdxRelationship `json:"relationships"`
+	}
+
+	spdxCreationInfo struct {
+		Created  string   `json:"created"`
+		Creators []string `json:"creators"`
+	}
+
+	spdxPackage struct {
+		Name                  string            `json:"name"`
+		SPDXID                string            `json:"SPDXID"`
+		DownloadLocation      string            `json:"downloadLocation"`
+		FilesAnalyzed         bool              `json:"filesAnalyzed"`
+		PrimaryPackagePurpose string            `json:"primaryPackagePurpose"`
+		SourceInfo            string            `json:"sourceInfo,omitempty"`
+		ExternalRefs          []spdxExternalRef `json:"externalRefs,omitempty"`
+		Comment               string            `json:"comment,omitempty"`
+	}
+
+	spdxExternalRef struct {
+		ReferenceCategory string `json:"referenceCategory"`
+		ReferenceType     string `json:"referenceType"`
+		ReferenceLocator  string `json:"referenceLocator"`
+	}
+
+	spdxRelationship struct {
+		SPDXElementID      string `json:"spdxElementId"`
+		RelationshipType   string `json:"relationshipType"`
+		RelatedSPDXElement string `json:"relatedSpdxElement"`
+	}
+)
+
+// sbomCommand is a command found by a scan and where it is used.
+type sbomCommand struct {
+	name     string
+	category string
+	// pkg is the package providing the command and purl its package URL,
+	// from Config.Packages.
+	pkg  string
+	purl string
+	uses []sbomUse
+}
+
+type sbomUse struct {
+	path string
+	occ  Occurrence
+}
+
+// sbomCommands returns the commands of r sorted by name, with the packages
+// Packages maps them to.
+func (r ScanResult) sbomCommands(c *Config) []sbomCommand {
+	byName := make(map[string]*sbomCommand)
+	for _, path := range slices.Sorted(maps.Keys(r)) {
+		for cmd, occs := range r[path] {
+			sc, ok := byName[cmd]
+			if !ok {
+				sc = &sbomCommand{name: cmd, category: c.Category(cmd)}
+				if pkg := c.Packages[cmd]; strings.HasPrefix(pkg, "pkg:") {
+					sc.purl = pkg
+				} else {
+					sc.pkg = pkg
+				}
+				byName[cmd] = sc
+			}
+			for _, occ := range occs {
+				sc.uses = append(sc.uses, sbomUse{path: path, occ: occ})
+			}
+		}
+	}
+
+	cmds := make([]sbomCommand, 0, len(byName))
+	for _, name := range slices.Sorted(maps.Keys(byName)) {
+		cmds = append(cmds, *byName[name])
+	}
+	return cmds
+}
+
+// sbomTime returns the creation time of SBOMs: SOURCE_DATE_EPOCH if set, for
+// reproducible builds, or else now.
+func sbomTime() string {
+	t := time.Now()
+	if epoch, err := strconv.ParseInt(os.Getenv("SOURCE_DATE_EPOCH"), 10, 64); err == nil {
+		t = time.Unix(epoch, 0)
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// sbomDigest returns a digest of the commands and their uses, which
+// identifies the document. The creation time is left out, so that documents
+// of the same findings have the same identity.
+func sbomDigest(cmds []sbomCommand) []byte {
+	h := sha256.New()
+	for _, sc := range cmds {
+		fmt.Fprintf(h, "%s\x00%s\x00%s\x00", sc.name, sc.pkg, sc.purl)
+		for _, u := range sc.uses {
+			fmt.Fprintf(h, "%s\x00%d\x00%d\x00", u.path, u.occ.Line, u.occ.Col)
+		}
+	}
+	return h.Sum(nil)
+}
+
+// CycloneDX returns the result as a CycloneDX 1.6 BOM, with a component per
+// command and its occurrences as evidence.
+func (r ScanResult) CycloneDX(c *Config) (string, error) {
+	cmds := r.sbomCommands(c)
+	timestamp := sbomTime()
+
+	// A version 4 UUID made of the digest, so the same scan gives the same BOM
+	d := sbomDigest(cmds)
+	d[6] = d[6]&0x0f | 0x40
+	d[8] = d[8]&0x3f | 0x80
+	serial := fmt.Sprintf("urn:uuid:%x-%x-%x-%x-%x", d[0:4], d[4:6], d[6:8], d[8:10], d[10:16])
+
+	bom := cdxBOM{
+		BOMFormat:    "CycloneDX",
+		SpecVersion:  cycloneDXVersion,
+		SerialNumber: serial,
+		Version:      1,
+		Components:   []cdxComponent{},
+	}
+	bom.Metadata.Timestamp = timestamp
+	bom.Metadata.Tools.Components = []cdxComponent{{Type: "application", Name: "depextify", Version: Version()}}
+
+	for _, sc := range cmds {
+		comp := cdxComponent{
+			Type:       "application",
+			BOMRef:     "command:" + sc.name,
+			Name:       sc.name,
+			PURL:       sc.purl,
+			Properties: []cdxProperty{{Name: "depextify:category", Value: sc.category}},
+			Evidence:   &cdxEvidence{},
+		}
+		if sc.pkg != "" {
+			comp.Properties = append(comp.Properties, cdxProperty{Name: "depextify:package", Value: sc.pkg})
+		}
+		for _, u := range sc.uses {
+			// offset is a position in the file, which is not known here, so
+			// the column goes with the context.
+			context := u.occ.Context
+			if u.occ.Col > 0 {
+				column := fmt.Sprintf("column %d", runeColumn(u.occ.FullLine, u.occ.Col))
+				if context == "" {
+					context = column
+				} else {
+					context += "; " + column
+				}
+			}
+			comp.Evidence.Occurrences = append(comp.Evidence.Occurrences, cdxOccurrence{
+				Location:          u.path,
+				Line:              u.occ.Line,
+				Symbol:            sc.name,
+				AdditionalContext: context,
+			})
+		}
+		bom.Components = append(bom.Components, comp)
+	}
+
+	b, err := json.MarshalIndent(bom, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// SPDX returns the result as an SPDX 2.3 document, with a package per command
+// whose source info lists where it is used.
+func (r ScanResult) SPDX(c *Config) (string, error) {
+	cmds := r.sbomCommands(c)
+	timestamp := sbomTime()
+
+	doc := spdxDocument{
+		SPDXVersion:       spdxVersion,
+		DataLicense:       "CC0-1.0",
+		SPDXID:            "SPDXRef-DOCUMENT",
+		Name:              "depextify",
+		DocumentNamespace: "https://spdx.org/spdxdocs/depextify-" + hex.EncodeToString(sbomDigest(cmds)[:16]),
+		CreationInfo: spdxCreationInfo{
+			Created:  timestamp,
+			Creators: []string{"Tool: depextify-" + Version()},
+		},
+		Packages:      []spdxPackage{},
+		Relationships: []spdxRelationship{},
+	}
+
+	for i, sc := range cmds {
+		// IDs may only contain letters, digits, "." and "-"; the index keeps
+		// them unique.
+		id := fmt.Sprintf("SPDXRef-Command-%d-%s", i+1, reSPDXID.ReplaceAllString(sc.name, "-"))
+		pkg := spdxPackage{
+			Name:                  sc.name,
+			SPDXID:                id,
+			DownloadLocation:      "NOASSERTION",
+			PrimaryPackagePurpose: "APPLICATION",
+			Comment:               "depextify category: " + sc.category,
+		}
+		if sc.pkg != "" {
+			pkg.Comment += "; package: " + sc.pkg
+		}
+		if sc.purl != "" {
+			pkg.ExternalRefs = []spdxExternalRef{{ReferenceCategory: "PACKAGE-MANAGER", ReferenceType: "purl", ReferenceLocator: sc.purl}}
+		}
+		uses := make([]string, 0, len(sc.uses))
+		for _, u := range sc.uses {
+			uses = append(uses, fmt.Sprintf("%s:%d:%d", u.path, u.occ.Line, runeColumn(u.occ.FullLine, u.occ.Col)))
+		}
+		pkg.SourceInfo = "used at " + strings.Join(uses, ", ")
+
+		doc.Packages = append(doc.Packages, pkg)
+		doc.Relationships = append(doc.Relationships, spdxRelationship{
+			SPDXElementID:      "SPDXRef-DOCUMENT",
+			RelationshipType:   "DESCRIBES",
+			RelatedSPDXElement: id,
+		})
+	}
+
+	b, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
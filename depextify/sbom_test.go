@@ -0,0 +1,124 @@
+package depextify
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResult_SBOM(t *testing.T) {
+	t.Setenv("SOURCE_DATE_EPOCH", "1700000000")
+
+	res := ScanResult{
+		"scripts/deploy.sh": {
+			"jq":      {{Line: 2, Col: 13, Len: 2, FullLine: "echo 'é' | jq ."}},
+			"aws":     {{Line: 5, Col: 1, Len: 3, FullLine: "aws s3 sync dist/ s3://bucket"}},
+			"g++_x86": {{Line: 6, Col: 1, Len: 7, FullLine: "g++_x86 main.cc"}},
+		},
+		"Makefile": {
+			"jq": {{Line: 3, Col: 2, Len: 2, FullLine: "\tjq . x", Context: "target build"}},
+		},
+	}
+	config := &Config{Packages: map[string]string{"jq": "pkg:deb/debian/jq", "aws": "awscli"}}
+
+	t.Run("CycloneDX", func(t *testing.T) {
+		out, err := res.CycloneDX(config)
+		require.NoError(t, err)
+
+		var bom struct {
+			BOMFormat    string
+			SpecVersion  string
+			SerialNumber string
+			Metadata     struct{ Timestamp string }
+			Components   []struct {
+				Name       string
+				BOMRef     string `json:"bom-ref"`
+				PURL       string
+				Properties []struct{ Name, Value string }
+				Evidence   struct {
+					Occurrences []struct {
+						Location          string
+						Line              int
+						Symbol            string
+						AdditionalContext string
+					}
+				}
+			}
+		}
+		require.NoError(t, json.Unmarshal([]byte(out), &bom))
+		require.Equal(t, "CycloneDX", bom.BOMFormat)
+		require.Equal(t, "1.6", bom.SpecVersion)
+		require.Regexp(t, `^urn:uuid:[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`, bom.SerialNumber)
+		require.Equal(t, "2023-11-14T22:13:20Z", bom.Metadata.Timestamp)
+
+		require.Len(t, bom.Components, 3)
+		aws, jq := bom.Components[0], bom.Components[2]
+		require.Equal(t, "aws", aws.Name)
+		require.Empty(t, aws.PURL)
+		require.Contains(t, aws.Properties, struct{ Name, Value string }{"depextify:package", "awscli"})
+
+		require.Equal(t, "jq", jq.Name)
+		require.Equal(t, "command:jq", jq.BOMRef)
+		require.Equal(t, "pkg:deb/debian/jq", jq.PURL)
+		require.Contains(t, jq.Properties, struct{ Name, Value string }{"depextify:category", "external"})
+		require.Len(t, jq.Evidence.Occurrences, 2)
+		require.Equal(t, "Makefile", jq.Evidence.Occurrences[0].Location)
+		require.Equal(t, "target build; column 2", jq.Evidence.Occurrences[0].AdditionalContext)
+		require.Equal(t, "scripts/deploy.sh", jq.Evidence.Occurrences[1].Location)
+		require.Equal(t, 2, jq.Evidence.Occurrences[1].Line)
+		require.Equal(t, "jq", jq.Evidence.Occurrences[1].Symbol)
+		require.Equal(t, "column 12", jq.Evidence.Occurrences[1].AdditionalContext)
+		require.NotContains(t, out, `"offset"`)
+
+		// The same scan gives the same BOM
+		again, err := res.CycloneDX(config)
+		require.NoError(t, err)
+		require.Equal(t, out, again)
+
+		// at any time, but for the timestamp
+		t.Setenv("SOURCE_DATE_EPOCH", "1800000000")
+		later, err := res.CycloneDX(config)
+		require.NoError(t, err)
+		require.NoError(t, json.Unmarshal([]byte(later), &bom))
+		require.Equal(t, "2027-01-15T08:00:00Z", bom.Metadata.Timestamp)
+		require.Contains(t, out, bom.SerialNumber)
+	})
+
+	t.Run("SPDX", func(t *testing.T) {
+		out, err := res.SPDX(config)
+		require.NoError(t, err)
+
+		var doc struct {
+			SPDXVersion       string
+			DocumentNamespace string
+			CreationInfo      struct{ Created string }
+			Packages          []struct {
+				Name         string
+				SPDXID       string
+				SourceInfo   string
+				Comment      string
+				ExternalRefs []struct{ ReferenceType, ReferenceLocator string }
+			}
+			Relationships []struct {
+				SPDXElementID, RelationshipType, RelatedSPDXElement string
+			}
+		}
+		require.NoError(t, json.Unmarshal([]byte(out), &doc))
+		require.Equal(t, "SPDX-2.3", doc.SPDXVersion)
+		require.Regexp(t, `^https://spdx.org/spdxdocs/depextify-[0-9a-f]{32}$`, doc.DocumentNamespace)
+		require.Equal(t, "2023-11-14T22:13:20Z", doc.CreationInfo.Created)
+
+		require.Len(t, doc.Packages, 3)
+		require.Equal(t, "SPDXRef-Command-2-g-x86", doc.Packages[1].SPDXID)
+		jq := doc.Packages[2]
+		require.Equal(t, "SPDXRef-Command-3-jq", jq.SPDXID)
+		require.Equal(t, "used at Makefile:3:2, scripts/deploy.sh:2:12", jq.SourceInfo)
+		require.Equal(t, "pkg:deb/debian/jq", jq.ExternalRefs[0].ReferenceLocator)
+		require.Equal(t, "depextify category: external; package: awscli", doc.Packages[0].Comment)
+
+		require.Len(t, doc.Relationships, 3)
+		require.Equal(t, "DESCRIBES", doc.Relationships[2].RelationshipType)
+		require.Equal(t, jq.SPDXID, doc.Relationships[2].RelatedSPDXElement)
+	})
+}
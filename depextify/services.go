@@ -0,0 +1,272 @@
+package depextify
+
+import (
+	"bufio"
+	"bytes"
+	"path"
+	"regexp"
+	"strings"
+
+	"mvdan.cc/sh/v3/syntax"
+)
+
+type (
+	// SystemdExtractor extracts commands from Exec*= lines of systemd units.
+	SystemdExtractor struct{}
+
+	// CrontabExtractor extracts commands from crontab files.
+	CrontabExtractor struct {
+		// System crontabs (/etc/crontab, /etc/cron.d/*) have a user field
+		// between the schedule and the command.
+		System bool
+	}
+
+	// SupervisordExtractor extracts commands from command= lines of supervisord configs.
+	SupervisordExtractor struct{}
+)
+
+var (
+	reSystemdUnit    = regexp.MustCompile(`\.(service|socket|timer|path|mount)$`)
+	reSystemdExec    = regexp.MustCompile(`^(\s*Exec[A-Za-z]+\s*=\s*)([-@:+!]*)`)
+	reCrontab        = regexp.MustCompile(`(^crontab|\.crontab|\.cron)$`)
+	reSystemCrontab  = regexp.MustCompile(`(^|/)(etc/crontab|cron\.d/[^/]+)$`)
+	reCronEnv        = regexp.MustCompile(`^\s*[A-Za-z_][A-Za-z0-9_]*\s*=`)
+	reSupervisord    = regexp.MustCompile(`(^|/)(supervisord\.conf|supervisor[d]?/(conf\.d/)?[^/]+\.conf)$`)
+	reIniSection     = regexp.MustCompile(`^\s*\[([^\]]+)\]`)
+	reSupervisordCmd = regexp.MustCompile(`^(\s*command\s*[=:]\s*)`)
+)
+
+// shellCScript returns the script of code if it runs a shell with -c and a
+// quoted script, e.g. /bin/sh -c 'curl ...', with the line and the column of
+// its first byte in code.
+func shellCScript(code string) (string, int, int, bool) {
+	file, err := syntax.NewParser().Parse(strings.NewReader(code), "")
+	if err != nil || len(file.Stmts) == 0 {
+		return "", 0, 0, false
+	}
+	call, ok := file.Stmts[0].Cmd.(*syntax.CallExpr)
+	if !ok || len(call.Args) < 3 || !reShellName.MatchString(path.Base(call.Args[0].Lit())) || call.Args[1].Lit() != "-c" {
+		return "", 0, 0, false
+	}
+
+	arg := call.Args[2]
+	if len(arg.Parts) != 1 {
+		return "", 0, 0, false
+	}
+	var script string
+	switch q := arg.Parts[0].(type) {
+	case *syntax.SglQuoted:
+		script = q.Value
+	case *syntax.DblQuoted:
+		// Only literal scripts: expansions change what runs
+		for _, part := range q.Parts {
+			if _, ok := part.(*syntax.Lit); !ok {
+				return "", 0, 0, false
+			}
+		}
+		// As written, since continuations split the literal
+		script = code[q.Left.Offset()+1 : q.Right.Offset()]
+	default:
+		return "", 0, 0, false
+	}
+	// Skip the opening quote
+	return script, int(arg.Pos().Line()), int(arg.Pos().Col()) + 1, true
+}
+
+// addLineScript analyzes script, which starts at lineNum, and records its
+// commands tagged with ctx. Units and supervisord programs run their command
+// without a shell, so the script of sh -c is analyzed too.
+func addLineScript(script string, lineNum int, ctx string, results map[string][]posInfo) {
+	cmds, err := analyzeShellCode(script)
+	if err != nil {
+		return
+	}
+	for cmd, infos := range cmds {
+		for _, info := range infos {
+			info.line += uint(lineNum - 1)
+			info.ctx = ctx
+			results[cmd] = append(results[cmd], info)
+		}
+	}
+
+	if inner, line, col, ok := shellCScript(script); ok {
+		// Pad the first line to keep columns
+		addLineScript(strings.Repeat(" ", col-1)+inner, lineNum+line-1, ctx, results)
+	}
+}
+
+func (e *SystemdExtractor) Extract(content []byte) (map[string][]posInfo, error) {
+	results := make(map[string][]posInfo)
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+
+	lineNum := 0
+	var (
+		buffer    strings.Builder
+		startLine int
+		key       string
+	)
+
+	for scanner.Scan() {
+		lineNum++
+		line := scanner.Text()
+
+		if buffer.Len() > 0 {
+			buffer.WriteString("\n")
+			buffer.WriteString(line)
+		} else if m := reSystemdExec.FindStringSubmatch(line); m != nil {
+			// Blank out "ExecStart=" and the -@:+! prefixes to keep columns.
+			prefixLen := len(m[0])
+			key = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(m[1]), "="))
+			startLine = lineNum
+			buffer.WriteString(strings.Repeat(" ", prefixLen) + line[prefixLen:])
+		} else {
+			continue
+		}
+
+		if strings.HasSuffix(strings.TrimSpace(line), "\\") {
+			continue
+		}
+		addLineScript(buffer.String(), startLine, key, results)
+		buffer.Reset()
+	}
+	if buffer.Len() > 0 {
+		addLineScript(buffer.String(), startLine, key, results)
+	}
+
+	return results, nil
+}
+
+// cronCommandStart returns the byte offset of the command in a crontab entry,
+// or -1 if the line has no command.
+func cronCommandStart(line string, system bool) int {
+	fields := 5
+	if strings.HasPrefix(strings.TrimSpace(line), "@") {
+		// @reboot, @daily, ...
+		fields = 1
+	}
+	if system {
+		fields++
+	}
+
+	i := 0
+	for n := 0; n < fields; n++ {
+		for i < len(line) && (line[i] == ' ' || line[i] == '\t') {
+			i++
+		}
+		if i == len(line) {
+			return -1
+		}
+		for i < len(line) && line[i] != ' ' && line[i] != '\t' {
+			i++
+		}
+	}
+	for i < len(line) && (line[i] == ' ' || line[i] == '\t') {
+		i++
+	}
+	if i == len(line) {
+		return -1
+	}
+	return i
+}
+
+func (e *CrontabExtractor) Extract(content []byte) (map[string][]posInfo, error) {
+	results := make(map[string][]posInfo)
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") || reCronEnv.MatchString(line) {
+			continue
+		}
+
+		start := cronCommandStart(line, e.System)
+		if start < 0 {
+			continue
+		}
+
+		command := line[start:]
+		// An unescaped % ends the command; the rest is fed to its stdin.
+		for i := 0; i < len(command); i++ {
+			if command[i] == '\\' {
+				i++
+			} else if command[i] == '%' {
+				command = command[:i]
+				break
+			}
+		}
+
+		addLineScript(strings.Repeat(" ", start)+command, lineNum, "", results)
+	}
+
+	return results, nil
+}
+
+func (e *SupervisordExtractor) Extract(content []byte) (map[string][]posInfo, error) {
+	results := make(map[string][]posInfo)
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+
+	lineNum := 0
+	section := ""
+	var (
+		buffer strings.Builder
+		// starts holds, for each line of the value, its offset in buffer
+		// and its line number.
+		starts [][2]int
+		ctx    string
+	)
+	flush := func() {
+		if buffer.Len() == 0 {
+			return
+		}
+		cmds := make(map[string][]posInfo)
+		addLineScript(buffer.String(), 1, ctx, cmds)
+		for cmd, infos := range cmds {
+			for _, info := range infos {
+				// Back from the joined value to the line it is on
+				i := len(starts) - 1
+				for i > 0 && int(info.col)-1 < starts[i][0] {
+					i--
+				}
+				info.col -= uint(starts[i][0])
+				info.line += uint(starts[i][1] - 1)
+				results[cmd] = append(results[cmd], info)
+			}
+		}
+		buffer.Reset()
+		starts = starts[:0]
+	}
+
+	for scanner.Scan() {
+		lineNum++
+		line := scanner.Text()
+
+		// Indented lines continue the previous value, as more words of it.
+		if buffer.Len() > 0 && line != "" && (line[0] == ' ' || line[0] == '\t') {
+			buffer.WriteString(" ")
+			starts = append(starts, [2]int{buffer.Len(), lineNum})
+			buffer.WriteString(line)
+			continue
+		}
+		flush()
+
+		if m := reIniSection.FindStringSubmatch(line); m != nil {
+			section = strings.TrimSpace(m[1])
+			continue
+		}
+		if !strings.HasPrefix(section, "program:") && !strings.HasPrefix(section, "eventlistener:") && !strings.HasPrefix(section, "fcgi-program:") {
+			continue
+		}
+
+		if m := reSupervisordCmd.FindString(line); m != "" {
+			ctx = section
+			starts = append(starts, [2]int{0, lineNum})
+			buffer.WriteString(strings.Repeat(" ", len(m)) + line[len(m):])
+		}
+	}
+	flush()
+
+	return results, nil
+}
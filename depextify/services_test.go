@@ -0,0 +1,95 @@
+package depextify
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtractSystemd(t *testing.T) {
+	content := `[Unit]
+Description=App
+
+[Service]
+ExecStartPre=-/usr/bin/docker pull app:latest
+ExecStart=@/usr/bin/app app-daemon --port 8080 \
+    --verbose
+ExecStop=!!/bin/sh -c 'curl -X POST localhost/stop'
+# ExecReload=ignored
+`
+	extractor := &SystemdExtractor{}
+	res, err := extractor.Extract([]byte(content))
+	require.NoError(t, err)
+
+	require.Equal(t, []posInfo{{line: 5, col: 15, len: 15, ctx: "ExecStartPre"}}, res["/usr/bin/docker"])
+	require.Equal(t, []posInfo{{line: 6, col: 12, len: 12, ctx: "ExecStart"}}, res["/usr/bin/app"])
+	require.Equal(t, []posInfo{{line: 8, col: 12, len: 7, ctx: "ExecStop"}}, res["/bin/sh"])
+	require.Equal(t, []posInfo{{line: 8, col: 24, len: 4, ctx: "ExecStop"}}, res["curl"]) // script of sh -c
+	require.NotContains(t, res, "app-daemon")
+	require.NotContains(t, res, "ignored")
+
+	res, err = extractor.Extract([]byte("ExecStartPost=/bin/bash -c \"mkdir -p /run/app && \\\n  chown app /run/app\"\nExecReload=/bin/sh -c \"kill -HUP $MAINPID\"\n"))
+	require.NoError(t, err)
+	require.Equal(t, []posInfo{{line: 2, col: 3, len: 5, ctx: "ExecStartPost"}}, res["chown"])
+	require.NotContains(t, res, "kill") // not a literal script
+}
+
+func TestExtractCrontab(t *testing.T) {
+	content := `SHELL=/bin/bash
+MAILTO=ops@example.com
+# m h dom mon dow command
+*/5 * * * * /usr/local/bin/backup.sh && restic check
+@reboot     redis-server /etc/redis.conf
+0 0 * * *   date +%Y-%m-%d | mail -s report ops
+`
+	t.Run("user crontab", func(t *testing.T) {
+		res, err := (&CrontabExtractor{}).Extract([]byte(content))
+		require.NoError(t, err)
+		require.Equal(t, []posInfo{{line: 4, col: 13, len: 24}}, res["/usr/local/bin/backup.sh"])
+		require.Equal(t, []posInfo{{line: 4, col: 41, len: 6}}, res["restic"])
+		require.Equal(t, []posInfo{{line: 5, col: 13, len: 12}}, res["redis-server"])
+		require.Contains(t, res, "date")
+		require.NotContains(t, res, "mail") // after %, stdin data
+		require.NotContains(t, res, "SHELL")
+	})
+
+	t.Run("system crontab", func(t *testing.T) {
+		res, err := (&CrontabExtractor{System: true}).Extract([]byte("17 * * * * root cd / && run-parts --report /etc/cron.hourly\n@reboot root certbot renew\n"))
+		require.NoError(t, err)
+		require.Contains(t, res, "run-parts")
+		require.Contains(t, res, "certbot")
+		require.NotContains(t, res, "root")
+	})
+}
+
+func TestExtractSupervisord(t *testing.T) {
+	content := `[supervisord]
+nodaemon=true
+
+[program:worker]
+command=celery -A app worker
+  --loglevel=info
+autorestart=true
+
+[program:web]
+command = gunicorn app:wsgi
+
+[program:backup]
+command=/bin/sh -c
+  "restic backup /data
+    && curl -fsS https://hc-ping.com/uuid"
+
+[include]
+files = /etc/supervisor/conf.d/*.conf
+`
+	res, err := (&SupervisordExtractor{}).Extract([]byte(content))
+	require.NoError(t, err)
+	require.Equal(t, map[string][]posInfo{
+		"celery":   {{line: 5, col: 9, len: 6, ctx: "program:worker"}},
+		"gunicorn": {{line: 10, col: 11, len: 8, ctx: "program:web"}},
+		"/bin/sh":  {{line: 13, col: 9, len: 7, ctx: "program:backup"}},
+		"restic":   {{line: 14, col: 4, len: 6, ctx: "program:backup"}},
+		"curl":     {{line: 15, col: 8, len: 4, ctx: "program:backup"}},
+	}, res)
+	require.NotContains(t, res, "--loglevel=info")
+}
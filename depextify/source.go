@@ -0,0 +1,431 @@
+package depextify
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type (
+	// GoExtractor extracts commands run through os/exec from Go source.
+	GoExtractor struct{}
+
+	// PythonExtractor extracts commands run through subprocess and os from Python source.
+	PythonExtractor struct{}
+
+	// NodeExtractor extracts commands run through child_process and execa from JavaScript/TypeScript source.
+	NodeExtractor struct{}
+
+	// RubyExtractor extracts commands run through backticks, %x, system and friends from Ruby source.
+	RubyExtractor struct{}
+
+	// RustExtractor extracts commands run through std::process::Command from Rust source.
+	RustExtractor struct{}
+
+	sourceCallKind int
+
+	// sourceCall is a call pattern ending with a string literal that names a
+	// command. The literal's value is the only capture group of the pattern.
+	sourceCall struct {
+		re   *regexp.Regexp
+		kind sourceCallKind
+	}
+
+	// sourceLanguage describes the subprocess calls of a language.
+	sourceLanguage struct {
+		calls []sourceCall
+		// shellArg matches right after an argv[0] literal naming a shell and
+		// captures the script passed with -c.
+		shellArg *regexp.Regexp
+		// isShell reports, given the source following the literal of a
+		// callMaybeShell call, whether the literal goes through a shell.
+		isShell func(rest []byte) bool
+	}
+)
+
+const (
+	// The literal is argv[0].
+	callArgv sourceCallKind = iota
+	// The literal is a shell command line.
+	callShell
+	// The literal is a shell command line if isShell says so, argv[0] otherwise.
+	callMaybeShell
+)
+
+const (
+	dqLiteral       = `"((?:[^"\\\n]|\\.)*)"`
+	sqLiteral       = `'((?:[^'\\\n]|\\.)*)'`
+	templateLiteral = "`((?:[^`\\\\]|\\\\.)*)`"
+
+	pyLiteral   = `[rRbBuUfF]{0,2}(?:` + dqLiteral + `|` + sqLiteral + `)`
+	jsLiteral   = `(?:` + dqLiteral + `|` + sqLiteral + `|` + templateLiteral + `)`
+	rubyLiteral = `(?:` + dqLiteral + `|` + sqLiteral + `)`
+
+	// Prefix of a call to a child_process function, qualified or destructured.
+	jsCallPrefix = `(?:\b(?:child_process|childProcess|cp)\.|(?:^|[^.\w$]))`
+)
+
+var (
+	reShellName  = regexp.MustCompile(`^(ba|z|k|da)?sh$`)
+	rePyShellArg = regexp.MustCompile(`\bshell\s*=\s*True\b`)
+
+	pythonLanguage = sourceLanguage{
+		calls: []sourceCall{
+			{regexp.MustCompile(`\bsubprocess\.(?:run|call|check_call|check_output|Popen)\(\s*[\[(]\s*` + pyLiteral), callArgv},
+			{regexp.MustCompile(`\bsubprocess\.(?:run|call|check_call|check_output|Popen)\(\s*` + pyLiteral), callMaybeShell},
+			{regexp.MustCompile(`\bsubprocess\.(?:getoutput|getstatusoutput)\(\s*` + pyLiteral), callShell},
+			{regexp.MustCompile(`\bos\.(?:system|popen)\(\s*` + pyLiteral), callShell},
+			{regexp.MustCompile(`\bos\.(?:exec|spawn)[lv]p?e?\(\s*(?:os\.P_\w+\s*,\s*)?` + pyLiteral), callArgv},
+			{regexp.MustCompile(`\bshutil\.which\(\s*` + pyLiteral), callArgv},
+		},
+		shellArg: regexp.MustCompile(`^\s*,\s*["']-c["']\s*,\s*` + pyLiteral),
+		isShell: func(rest []byte) bool {
+			return rePyShellArg.MatchString(callArgs(rest))
+		},
+	}
+
+	nodeLanguage = sourceLanguage{
+		calls: []sourceCall{
+			{regexp.MustCompile(jsCallPrefix + `(?:exec|execSync)\(\s*` + jsLiteral), callShell},
+			{regexp.MustCompile(jsCallPrefix + `(?:spawn|spawnSync|execFile|execFileSync)\(\s*` + jsLiteral), callArgv},
+			{regexp.MustCompile(`(?:^|[^.\w$])execa(?:Sync)?\(\s*` + jsLiteral), callArgv},
+			{regexp.MustCompile(`(?:^|[^.\w$])execaCommand(?:Sync)?\(\s*` + jsLiteral), callShell},
+		},
+		shellArg: regexp.MustCompile(`^\s*,\s*\[\s*` + "[\"'`]-c[\"'`]" + `\s*,\s*` + jsLiteral),
+	}
+
+	rubyLanguage = sourceLanguage{
+		calls: []sourceCall{
+			{regexp.MustCompile(`(?:^|[^.\w])(?:Kernel\.)?(?:system|exec|spawn|sh)(?:\(\s*|[ \t]+)` + rubyLiteral), callMaybeShell},
+			{regexp.MustCompile(`\b(?:Open3\.(?:capture2e?|capture3|popen2e?|popen3)|IO\.popen|PTY\.spawn)(?:\(\s*|[ \t]+)` + rubyLiteral), callMaybeShell},
+		},
+		shellArg: regexp.MustCompile(`^\s*,\s*["']-c["']\s*,\s*` + rubyLiteral),
+		isShell: func(rest []byte) bool {
+			// A single command string goes through the shell; argv form does not.
+			return !bytes.HasPrefix(bytes.TrimLeft(rest, " \t"), []byte(","))
+		},
+	}
+
+	rustLanguage = sourceLanguage{
+		calls: []sourceCall{
+			{regexp.MustCompile(`\bCommand::new\(\s*` + dqLiteral), callArgv},
+		},
+		shellArg: regexp.MustCompile(`^\s*\)\s*\.arg\(\s*"-c"\s*\)\s*\.arg\(\s*` + dqLiteral),
+	}
+
+	// Ruby backticks and %x{...} always run through the shell.
+	reRubyBacktick = regexp.MustCompile("(?:^|[^\\w$])`((?:[^`\\\\]|\\\\.)*)`")
+	reRubyPercentX = regexp.MustCompile(`%x(?:\{([^}]*)\}|\(([^)]*)\)|\[([^\]]*)\]|\|([^|]*)\|)`)
+)
+
+// maskSource returns a copy of content with its comments, starting with
+// lineComment or between /* and */ if blockComments, replaced by spaces, so
+// calls in comments are not found but offsets are kept. With maskStrings, the
+// contents of '...' and "..." literals are blanked out too. Backquoted
+// literals are kept, as they are commands in Ruby.
+func maskSource(content []byte, lineComment string, blockComments, maskStrings bool) []byte {
+	out := bytes.Clone(content)
+	blank := func(from, to int) {
+		for i := from; i < to; i++ {
+			if out[i] != '\n' {
+				out[i] = ' '
+			}
+		}
+	}
+
+	for i := 0; i < len(content); {
+		rest := content[i:]
+		switch {
+		case bytes.HasPrefix(rest, []byte(lineComment)):
+			end := bytes.IndexByte(rest, '\n')
+			if end < 0 {
+				end = len(rest)
+			}
+			blank(i, i+end)
+			i += end
+		case blockComments && bytes.HasPrefix(rest, []byte("/*")):
+			end := bytes.Index(rest[2:], []byte("*/"))
+			if end < 0 {
+				end = len(rest)
+			} else {
+				end += 4
+			}
+			blank(i, i+end)
+			i += end
+		case rest[0] == '"' || rest[0] == '\'' || rest[0] == '`':
+			end := literalEnd(rest)
+			if maskStrings && rest[0] != '`' {
+				blank(i+1, i+end-1)
+			}
+			i += end
+		default:
+			i++
+		}
+	}
+	return out
+}
+
+// literalEnd returns the length of the quoted literal at the start of src,
+// up to the end of the line for unterminated ones. Triple-quoted Python
+// strings and backquoted literals may span lines.
+func literalEnd(src []byte) int {
+	quote := src[0]
+	if triple := bytes.Repeat(src[:1], 3); quote != '`' && bytes.HasPrefix(src, triple) {
+		if end := bytes.Index(src[3:], triple); end >= 0 {
+			return end + 6
+		}
+		return len(src)
+	}
+	for i := 1; i < len(src); i++ {
+		switch src[i] {
+		case '\\':
+			i++
+		case quote:
+			return i + 1
+		case '\n':
+			if quote != '`' {
+				return i
+			}
+		}
+	}
+	return len(src)
+}
+
+// firstGroup returns the start and end of the first participating capture
+// group of a submatch index slice.
+func firstGroup(m []int) (int, int, bool) {
+	for i := 2; i+1 < len(m); i += 2 {
+		if m[i] >= 0 {
+			return m[i], m[i+1], true
+		}
+	}
+	return 0, 0, false
+}
+
+// callArgs returns the arguments in rest up to the parenthesis closing the
+// enclosing call, skipping over nested brackets and quoted strings.
+func callArgs(rest []byte) string {
+	depth := 0
+	var quote byte
+	for i := 0; i < len(rest); i++ {
+		c := rest[i]
+		if quote != 0 {
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+			continue
+		}
+		switch c {
+		case '"', '\'':
+			quote = c
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			if depth == 0 {
+				return string(rest[:i])
+			}
+			depth--
+		}
+	}
+	return string(rest)
+}
+
+// addLiteralCommand records the command named by the literal at offset.
+func addLiteralCommand(cmd string, offset int, idx lineIndex, results map[string][]posInfo) {
+	if cmd == "" {
+		return
+	}
+	line, col := idx.position(offset)
+	results[cmd] = append(results[cmd], posInfo{line: line, col: col, len: uint(len(cmd))})
+}
+
+// addLiteralScript analyzes the literal at offset as shell code and records
+// its commands at their positions in the source file.
+func addLiteralScript(script string, offset int, idx lineIndex, results map[string][]posInfo) {
+	cmds, err := analyzeShellCode(script)
+	if err != nil {
+		return
+	}
+	line, col := idx.position(offset)
+	for cmd, infos := range cmds {
+		for _, info := range infos {
+			if info.line == 1 {
+				info.col += col - 1
+			}
+			info.line += line - 1
+			results[cmd] = append(results[cmd], info)
+		}
+	}
+}
+
+// extractSourceCalls finds the calls of lang in content and records the
+// commands their literal arguments run.
+func extractSourceCalls(content []byte, lang sourceLanguage) map[string][]posInfo {
+	results := make(map[string][]posInfo)
+	idx := newLineIndex(content)
+
+	for _, call := range lang.calls {
+		for _, m := range call.re.FindAllSubmatchIndex(content, -1) {
+			start, end, ok := firstGroup(m)
+			if !ok {
+				continue
+			}
+			value := string(content[start:end])
+			// Source following the closing quote
+			rest := content[m[1]:]
+
+			kind := call.kind
+			if kind == callMaybeShell {
+				kind = callArgv
+				if lang.isShell != nil && lang.isShell(rest) {
+					kind = callShell
+				}
+			}
+
+			switch kind {
+			case callShell:
+				addLiteralScript(value, start, idx, results)
+			case callArgv:
+				cmd, _, _ := strings.Cut(strings.TrimSpace(value), " ")
+				addLiteralCommand(cmd, start+strings.Index(value, cmd), idx, results)
+				if lang.shellArg == nil || !reShellName.MatchString(path.Base(cmd)) {
+					continue
+				}
+				if sm := lang.shellArg.FindSubmatchIndex(rest); sm != nil {
+					if s, e, ok := firstGroup(sm); ok {
+						addLiteralScript(string(rest[s:e]), m[1]+s, idx, results)
+					}
+				}
+			}
+		}
+	}
+
+	return results
+}
+
+func (e *PythonExtractor) Extract(content []byte) (map[string][]posInfo, error) {
+	return extractSourceCalls(maskSource(content, "#", false, false), pythonLanguage), nil
+}
+
+func (e *NodeExtractor) Extract(content []byte) (map[string][]posInfo, error) {
+	return extractSourceCalls(maskSource(content, "//", true, false), nodeLanguage), nil
+}
+
+func (e *RubyExtractor) Extract(content []byte) (map[string][]posInfo, error) {
+	results := extractSourceCalls(maskSource(content, "#", false, false), rubyLanguage)
+	idx := newLineIndex(content)
+
+	// Backticks and %x in strings are text, not commands
+	code := maskSource(content, "#", false, true)
+	for _, re := range []*regexp.Regexp{reRubyBacktick, reRubyPercentX} {
+		for _, m := range re.FindAllSubmatchIndex(code, -1) {
+			if start, end, ok := firstGroup(m); ok {
+				addLiteralScript(string(content[start:end]), start, idx, results)
+			}
+		}
+	}
+
+	return results, nil
+}
+
+func (e *RustExtractor) Extract(content []byte) (map[string][]posInfo, error) {
+	return extractSourceCalls(maskSource(content, "//", true, false), rustLanguage), nil
+}
+
+// goStringLit returns the value of a Go string literal expression.
+func goStringLit(expr ast.Expr) (*ast.BasicLit, string, bool) {
+	lit, ok := expr.(*ast.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		return nil, "", false
+	}
+	value, err := strconv.Unquote(lit.Value)
+	if err != nil {
+		return nil, "", false
+	}
+	return lit, value, true
+}
+
+func (e *GoExtractor) Extract(content []byte) (map[string][]posInfo, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", content, parser.SkipObjectResolution)
+	if err != nil {
+		return nil, err
+	}
+
+	// Local names of the packages providing exec.Command.
+	execNames := make(map[string]bool)
+	for _, imp := range file.Imports {
+		p, _ := strconv.Unquote(imp.Path.Value)
+		if p != "os/exec" && p != "golang.org/x/sys/execabs" {
+			continue
+		}
+		name := path.Base(p)
+		if imp.Name != nil {
+			name = imp.Name.Name
+		}
+		execNames[name] = true
+	}
+	if len(execNames) == 0 {
+		return map[string][]posInfo{}, nil
+	}
+
+	idx := newLineIndex(content)
+	results := make(map[string][]posInfo)
+
+	ast.Inspect(file, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		sel, ok := call.Fun.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		pkg, ok := sel.X.(*ast.Ident)
+		if !ok || !execNames[pkg.Name] {
+			return true
+		}
+
+		args := call.Args
+		switch sel.Sel.Name {
+		case "Command", "LookPath":
+		case "CommandContext":
+			if len(args) == 0 {
+				return true
+			}
+			args = args[1:]
+		default:
+			return true
+		}
+		if len(args) == 0 {
+			return true
+		}
+
+		lit, cmd, ok := goStringLit(args[0])
+		if !ok {
+			return true
+		}
+		// Skip the opening quote
+		offset := fset.Position(lit.Pos()).Offset + 1
+		addLiteralCommand(cmd, offset, idx, results)
+
+		if reShellName.MatchString(path.Base(cmd)) && len(args) >= 3 {
+			if _, flag, ok := goStringLit(args[1]); ok && flag == "-c" {
+				if script, _, ok := goStringLit(args[2]); ok {
+					raw := script.Value[1 : len(script.Value)-1]
+					addLiteralScript(raw, fset.Position(script.Pos()).Offset+1, idx, results)
+				}
+			}
+		}
+		return true
+	})
+
+	return results, nil
+}
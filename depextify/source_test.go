@@ -0,0 +1,142 @@
+package depextify
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtractGo(t *testing.T) {
+	content := `package main
+
+import (
+	"context"
+	osexec "os/exec"
+)
+
+func run(ctx context.Context, name string) {
+	_ = osexec.Command("git", "status").Run()
+	_ = osexec.CommandContext(ctx, "ffmpeg", "-i", name).Run()
+	_ = osexec.Command("sh", "-c", "convert a.png b.jpg | tee log").Run()
+	_, _ = osexec.LookPath("docker")
+	_ = osexec.Command(name).Run()
+}
+`
+	extractor := &GoExtractor{}
+	res, err := extractor.Extract([]byte(content))
+	require.NoError(t, err)
+
+	require.Equal(t, []posInfo{{line: 9, col: 22, len: 3}}, res["git"])
+	require.Equal(t, []posInfo{{line: 10, col: 34, len: 6}}, res["ffmpeg"])
+	require.Equal(t, []posInfo{{line: 11, col: 34, len: 7, pipe: "tee"}}, res["convert"])
+	require.Contains(t, res, "sh")
+	require.Contains(t, res, "tee")
+	require.Contains(t, res, "docker")
+	require.Len(t, res, 6)
+
+	t.Run("without os/exec", func(t *testing.T) {
+		res, err := extractor.Extract([]byte("package main\n\nfunc Command(string) {}\n\nfunc main() { Command(\"git\") }\n"))
+		require.NoError(t, err)
+		require.Empty(t, res)
+	})
+
+	t.Run("syntax error", func(t *testing.T) {
+		_, err := extractor.Extract([]byte("package main\nfunc {"))
+		require.Error(t, err)
+	})
+}
+
+func TestExtractPython(t *testing.T) {
+	content := `import os, shutil, subprocess
+
+subprocess.run(["ffmpeg", "-i", src, dst], check=True)
+subprocess.check_output("pg_dump -Fc db | gzip > out", shell=True)
+subprocess.Popen(
+    ["bash", "-c", "aws s3 sync . s3://bucket"],
+)
+os.system('terraform plan')
+if shutil.which("docker") is None:
+    pass
+# os.system("make")
+os.popen("grep '#' notes | jq .")
+`
+	extractor := &PythonExtractor{}
+	res, err := extractor.Extract([]byte(content))
+	require.NoError(t, err)
+
+	require.Equal(t, []posInfo{{line: 3, col: 18, len: 6}}, res["ffmpeg"])
+	require.Equal(t, []posInfo{{line: 4, col: 26, len: 7, pipe: "gzip"}}, res["pg_dump"])
+	require.Contains(t, res, "gzip")
+	require.Equal(t, []posInfo{{line: 6, col: 21, len: 3}}, res["aws"])
+	require.Contains(t, res, "bash")
+	require.Equal(t, []posInfo{{line: 8, col: 12, len: 9}}, res["terraform"])
+	require.Contains(t, res, "docker")
+	require.NotContains(t, res, "make")
+	require.Contains(t, res, "jq") // after a # in a string
+}
+
+func TestExtractNode(t *testing.T) {
+	content := "const { exec, spawn } = require('child_process');\n" +
+		"exec('git describe --tags', cb);\n" +
+		"child_process.spawn(\"convert\", [\"a.png\", \"b.jpg\"]);\n" +
+		"/x/.exec('not-a-command');\n" +
+		"execSync(`docker build -t ${tag} .`);\n" +
+		"// exec('make'); /* spawn('helm') */\n"
+	extractor := &NodeExtractor{}
+	res, err := extractor.Extract([]byte(content))
+	require.NoError(t, err)
+
+	require.Equal(t, []posInfo{{line: 2, col: 7, len: 3}}, res["git"])
+	require.Equal(t, []posInfo{{line: 3, col: 22, len: 7}}, res["convert"])
+	require.Equal(t, []posInfo{{line: 5, col: 11, len: 6}}, res["docker"])
+	require.NotContains(t, res, "not-a-command")
+	require.NotContains(t, res, "make")
+	require.NotContains(t, res, "helm")
+}
+
+func TestExtractRuby(t *testing.T) {
+	content := "version = `git describe`\n" +
+		"system(\"bundle exec rake\")\n" +
+		"system(\"rsync\", \"-a\", src, dst)\n" +
+		"out = %x{kubectl get pods}\n" +
+		"sh \"yarn install\"\n"
+	extractor := &RubyExtractor{}
+	res, err := extractor.Extract([]byte(content))
+	require.NoError(t, err)
+
+	require.Equal(t, []posInfo{{line: 1, col: 12, len: 3}}, res["git"])
+	require.Equal(t, []posInfo{{line: 2, col: 9, len: 6}}, res["bundle"])
+	require.Equal(t, []posInfo{{line: 3, col: 9, len: 5}}, res["rsync"])
+	require.Equal(t, []posInfo{{line: 4, col: 10, len: 7}}, res["kubectl"])
+	require.Equal(t, []posInfo{{line: 5, col: 5, len: 4}}, res["yarn"])
+
+	t.Run("comments and strings", func(t *testing.T) {
+		content := "# run `make` first, or system(\"rm -rf /\")\n" +
+			"puts \"use `docker` or %x{podman}\" # %x(helm)\n" +
+			"ok = system('terraform plan') # `tflint`\n"
+		res, err := extractor.Extract([]byte(content))
+		require.NoError(t, err)
+		require.Equal(t, map[string][]posInfo{"terraform": {{line: 3, col: 14, len: 9}}}, res)
+	})
+}
+
+func TestExtractRust(t *testing.T) {
+	content := `use std::process::Command;
+
+fn main() {
+    Command::new("pg_dump").arg("-Fc").status().unwrap();
+    Command::new("sh").arg("-c").arg("psql < dump.sql").status().unwrap();
+}
+`
+	extractor := &RustExtractor{}
+	res, err := extractor.Extract([]byte(content))
+	require.NoError(t, err)
+
+	require.Equal(t, []posInfo{{line: 4, col: 19, len: 7}}, res["pg_dump"])
+	require.Equal(t, []posInfo{{line: 5, col: 39, len: 4}}, res["psql"])
+	require.Contains(t, res, "sh")
+
+	res, err = extractor.Extract([]byte("// Command::new(\"make\")\n/* Command::new(\"cargo\") */\n"))
+	require.NoError(t, err)
+	require.Empty(t, res)
+}
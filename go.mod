@@ -4,7 +4,6 @@ go 1.25.5
 
 require (
 	github.com/alecthomas/chroma/v2 v2.23.1
-	github.com/sabhiram/go-gitignore v0.0.0-20210923224102-525f6e181f06
 	github.com/stretchr/testify v1.11.1
 	gopkg.in/yaml.v3 v3.0.1
 	mvdan.cc/sh/v3 v3.12.0